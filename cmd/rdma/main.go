@@ -19,8 +19,15 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
@@ -56,9 +63,40 @@ var (
 	date    = "unknown date"
 )
 
+const (
+	// Templates supported in container RDMA device name
+	rdmaDevNameIfnameTmpl = "{ifname}"
+	rdmaDevNameIndexTmpl  = "{index}"
+	// Upper bound for {index} template expansion
+	maxRdmaDevNameIndex = 1024
+	// Max RDMA device name length as defined by the kernel (IB_DEVICE_NAME_MAX), including the terminating NUL
+	maxRdmaDevNameLen = 64
+	// Membership bit of InfiniBand partition key, set for full members
+	pkeyFullMember = 0x8000
+	// Max time to wait for RDMA devices, well below the time concurrent CNI invocations wait for locks
+	maxRdmaDevWaitTimeout = cache.DefaultLockTimeout / 3
+)
+
+const (
+	// Initial network namespace and directory of named network namespaces
+	initNetNsPath = "/proc/1/ns/net"
+	netNsRunDir   = "/var/run/netns"
+	// Glob of network namespaces of running processes
+	procNetNsGlob = "/proc/[0-9]*/ns/net"
+)
+
+// Error code returned by CNI STATUS when the plugin cannot serve ADD requests
+// as defined in https://github.com/containernetworking/cni/blob/main/SPEC.md#error
+const errPluginNotAvailable uint = 50
+
+// errRdmaDevMismatch is returned when an RDMA device is not the physical device recorded in its cached state
+var errRdmaDevMismatch = errors.New("RDMA device does not match cached state")
+
 type NsManager interface {
 	GetNS(string) (ns.NetNS, error)
 	GetCurrentNS() (ns.NetNS, error)
+	// List network namespaces other than the initial one, a single path is returned per namespace
+	ListNonInitNS() ([]string, error)
 }
 
 type nsManagerImpl struct {
@@ -72,6 +110,37 @@ func (nsm *nsManagerImpl) GetCurrentNS() (ns.NetNS, error) {
 	return ns.GetCurrentNS()
 }
 
+func (nsm *nsManagerImpl) ListNonInitNS() ([]string, error) {
+	initNs, err := os.Stat(initNetNsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat initial network namespace. %v", err)
+	}
+	procNsPaths, err := filepath.Glob(procNetNsGlob)
+	if err != nil {
+		return nil, err
+	}
+	namedNsPaths, err := filepath.Glob(filepath.Join(netNsRunDir, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := []os.FileInfo{initNs}
+	var nsPaths []string
+	for _, nsPath := range append(namedNsPaths, procNsPaths...) {
+		nsInfo, err := os.Stat(nsPath)
+		if err != nil {
+			// process exited or named network namespace was removed
+			continue
+		}
+		if slices.ContainsFunc(seen, func(fi os.FileInfo) bool { return os.SameFile(fi, nsInfo) }) {
+			continue
+		}
+		seen = append(seen, nsInfo)
+		nsPaths = append(nsPaths, nsPath)
+	}
+	return nsPaths, nil
+}
+
 func newNsManager() NsManager {
 	return &nsManagerImpl{}
 }
@@ -79,23 +148,123 @@ func newNsManager() NsManager {
 type rdmaCniPlugin struct {
 	rdmaManager rdma.Manager
 	nsManager   NsManager
-	stateCache  cache.StateCache
+	// Create state cache in the given state directory, empty for the default one
+	newStateCache func(stateDir string) cache.StateCache
+	// State cache of the network, set by initStateCache()
+	stateCache cache.StateCache
+	locker     cache.Locker
+}
+
+// Init state cache in the state directory of the network configuration
+func (plugin *rdmaCniPlugin) initStateCache(conf *rdmatypes.RdmaNetConf) {
+	plugin.stateCache = plugin.newStateCache(conf.StateDir)
+}
+
+// Ensure state cache is on a persistent file system, if required by the network configuration
+func (plugin *rdmaCniPlugin) ensureStateCachePersistent(conf *rdmatypes.RdmaNetConf) error {
+	if !conf.RequirePersistentStateDir {
+		return nil
+	}
+	return plugin.stateCache.EnsurePersistent()
 }
 
-// Ensure RDMA subsystem mode is set to exclusive.
-func (plugin *rdmaCniPlugin) ensureRdmaSystemMode() error {
+// Ensure RDMA subsystem mode is set to exclusive, optionally switching to exclusive mode if it is not.
+// Switching is only requested by STATUS, ADD always runs once the container network namespace exists
+func (plugin *rdmaCniPlugin) ensureRdmaSystemMode(switchMode bool) error {
 	mode, err := plugin.rdmaManager.GetSystemRdmaMode()
 	if err != nil {
 		return fmt.Errorf("failed to get RDMA subsystem namespace awareness mode. %v", err)
 	}
 	log.Debug().Msgf("RDMA subsystem mode: %s", mode)
-	if mode != rdma.RdmaSysModeExclusive {
+	if mode == rdma.RdmaSysModeExclusive {
+		return nil
+	}
+	if !switchMode {
 		return fmt.Errorf("RDMA subsystem namespace awareness mode is set to %s, "+
 			"expecting it to be set to %s, invalid system configurations", mode, rdma.RdmaSysModeExclusive)
 	}
+	return plugin.switchToExclusiveRdmaSystemMode(mode)
+}
+
+// Switch RDMA subsystem mode to exclusive, kernel allows it only when no network namespaces
+// other than the initial one exist
+func (plugin *rdmaCniPlugin) switchToExclusiveRdmaSystemMode(mode string) error {
+	errPrefix := fmt.Sprintf("cannot switch RDMA subsystem namespace awareness mode from %s to %s",
+		mode, rdma.RdmaSysModeExclusive)
+	nsPaths, err := plugin.nsManager.ListNonInitNS()
+	if err != nil {
+		return fmt.Errorf("%s, failed to list network namespaces. %v", errPrefix, err)
+	}
+	if len(nsPaths) > 0 {
+		return fmt.Errorf("%s, %d network namespaces other than the initial one exist %v",
+			errPrefix, len(nsPaths), nsPaths)
+	}
+
+	if err = plugin.rdmaManager.SetSystemRdmaMode(rdma.RdmaSysModeExclusive); err != nil {
+		if errors.Is(err, syscall.EBUSY) {
+			return fmt.Errorf("%s, network namespaces other than the initial one were created meanwhile. %v",
+				errPrefix, err)
+		}
+		return fmt.Errorf("%s. %v", errPrefix, err)
+	}
+	log.Info().Msgf("RDMA subsystem namespace awareness mode switched from %s to %s", mode, rdma.RdmaSysModeExclusive)
 	return nil
 }
 
+// Resolve DeviceID from device-info file written for this network attachment, if device-info file is not
+// available attempt to derive it from the container network device or from PrevResult Mac address
+func (plugin *rdmaCniPlugin) resolveDeviceID(
+	conf *rdmatypes.RdmaNetConf, args *skel.CmdArgs, result *current.Result) (string, error) {
+	devInfoPath := conf.RuntimeConfig.CNIDeviceInfoFile
+	if devInfoPath == "" {
+		devInfoPath = utils.GetCNIDeviceInfoPath(conf.Name, args.ContainerID, args.IfName)
+	}
+	deviceID, err := utils.GetDeviceIDFromDeviceInfoFile(devInfoPath)
+	if err == nil {
+		log.Debug().Msgf("DeviceID %s resolved from device-info file %s", deviceID, devInfoPath)
+		return deviceID, nil
+	}
+	log.Debug().Msgf("failed to resolve DeviceID from device-info file. %v", err)
+
+	if deviceID, err = plugin.deriveDeviceIDFromSandbox(result, args.IfName); err == nil {
+		log.Debug().Msgf("DeviceID %s derived from container network device %s", deviceID, args.IfName)
+		return deviceID, nil
+	}
+	log.Debug().Msgf("failed to derive DeviceID from container network device. %v", err)
+	return plugin.deriveDeviceIDFromResult(result)
+}
+
+// Get sandbox of the given container interface in result, empty if not found
+func getSandbox(result *current.Result, ifName string) string {
+	for _, iface := range result.Interfaces {
+		if iface.Name == ifName && iface.Sandbox != "" {
+			return iface.Sandbox
+		}
+	}
+	return ""
+}
+
+// Derive DeviceID from the parent device of the container network device in its sandbox
+func (plugin *rdmaCniPlugin) deriveDeviceIDFromSandbox(result *current.Result, ifName string) (string, error) {
+	sandbox := getSandbox(result, ifName)
+	if sandbox == "" {
+		return "", fmt.Errorf("interface %s with a sandbox not found in prevResult", ifName)
+	}
+
+	sandboxNs, err := plugin.nsManager.GetNS(sandbox)
+	if err != nil {
+		return "", fmt.Errorf("failed to open network namespace %s. %v", sandbox, err)
+	}
+	defer sandboxNs.Close()
+
+	var deviceID string
+	err = sandboxNs.Do(func(_ ns.NetNS) error {
+		deviceID, err = plugin.rdmaManager.GetNetdevParentDev(ifName)
+		return err
+	})
+	return deviceID, err
+}
+
 func (plugin *rdmaCniPlugin) deriveDeviceIDFromResult(result *current.Result) (string, error) {
 	log.Warn().Msgf("DeviceID attribute in network configuration is empty, " +
 		"this may indicated that the delegate plugin is out of date.")
@@ -114,10 +283,37 @@ func (plugin *rdmaCniPlugin) deriveDeviceIDFromResult(result *current.Result) (s
 	return deviceID, nil
 }
 
+// Get CNI result interface describing the RDMA device attached to the container
+func newRdmaDevInterface(rdmaDev, deviceID, nsPath string) *current.Interface {
+	pciID := deviceID
+	if deviceID != "" && !utils.IsPCIAddress(deviceID) {
+		var err error
+		if pciID, err = utils.GetPciDevFromAuxDev(deviceID); err != nil {
+			log.Debug().Msgf("failed to get PCI device of %s. %v", deviceID, err)
+		}
+	}
+	return &current.Interface{Name: rdmaDev, Sandbox: nsPath, PciID: pciID}
+}
+
+// Add RDMA device interface to the result unless it is already there
+func addRdmaDevInterface(result *current.Result, rdmaDevIface *current.Interface) {
+	for _, iface := range result.Interfaces {
+		if iface.Name == rdmaDevIface.Name && iface.Sandbox == rdmaDevIface.Sandbox {
+			return
+		}
+	}
+	result.Interfaces = append(result.Interfaces, rdmaDevIface)
+}
+
 // Parse network configurations
 func (plugin *rdmaCniPlugin) parseConf(data []byte, envArgs string) (*rdmatypes.RdmaNetConf, error) {
 	conf := rdmatypes.RdmaNetConf{}
-	// Parse CNI args passed as env variables (not used ATM)
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("failed to load netconf: %+v", err)
+	}
+	log.Debug().Msgf("Network Configuration: %+v", conf)
+
+	// Parse CNI args passed as env variables, they take precedence over args in network configuration
 	if envArgs != "" {
 		commonCniArgs := &conf.Args.CNI
 		err := types.LoadArgs(envArgs, commonCniArgs)
@@ -127,13 +323,207 @@ func (plugin *rdmaCniPlugin) parseConf(data []byte, envArgs string) (*rdmatypes.
 		log.Debug().Msgf("ENV CNI_ARGS: %+v", commonCniArgs)
 	}
 
-	if err := json.Unmarshal(data, &conf); err != nil {
-		return nil, fmt.Errorf("failed to load netconf: %+v", err)
+	// RDMA device provided via CNI_ARGS or runtimeConfig overrides the one in network configuration
+	if conf.Args.CNI.RdmaDevice != "" {
+		conf.RdmaDevice = string(conf.Args.CNI.RdmaDevice)
+	}
+	if conf.RuntimeConfig.RdmaDevice != "" {
+		conf.RdmaDevice = conf.RuntimeConfig.RdmaDevice
+	}
+	// DeviceID provided via runtimeConfig overrides the one in network configuration
+	if conf.RuntimeConfig.DeviceID != "" {
+		conf.DeviceID = conf.RuntimeConfig.DeviceID
+	}
+	// GUID provided via runtimeConfig overrides the one in network configuration
+	if conf.RuntimeConfig.GUID != "" {
+		conf.GUID = conf.RuntimeConfig.GUID
+	}
+
+	if err := validateConf(&conf); err != nil {
+		return nil, err
 	}
-	log.Debug().Msgf("Network Configuration: %+v", conf)
 	return &conf, nil
 }
 
+// Validate network configurations
+func validateConf(conf *rdmatypes.RdmaNetConf) error {
+	switch conf.Mode {
+	case "", rdmatypes.RdmaNetModeExclusive:
+	case rdmatypes.RdmaNetModeShared:
+		if conf.ContainerRdmaDevName != "" {
+			return fmt.Errorf("containerRdmaDevName is not supported in %s mode", conf.Mode)
+		}
+		if conf.AutoExclusiveMode {
+			return fmt.Errorf("autoExclusiveMode is not supported in %s mode", conf.Mode)
+		}
+		if conf.GUID != "" {
+			return fmt.Errorf("guid is not supported in %s mode", conf.Mode)
+		}
+	default:
+		return fmt.Errorf("invalid mode %q, expecting one of [%s, %s]",
+			conf.Mode, rdmatypes.RdmaNetModeExclusive, rdmatypes.RdmaNetModeShared)
+	}
+	if strings.ContainsAny(conf.RdmaDevice, "/% ") {
+		return fmt.Errorf("invalid rdmaDevice %q", conf.RdmaDevice)
+	}
+	if nameTmpl := conf.ContainerRdmaDevName; nameTmpl != "" {
+		if strings.ContainsAny(nameTmpl, "/% ") {
+			return fmt.Errorf("invalid containerRdmaDevName %q", nameTmpl)
+		}
+		if conf.AllRdmaDevs && !strings.Contains(nameTmpl, rdmaDevNameIndexTmpl) {
+			return fmt.Errorf("containerRdmaDevName %q must contain %s template when allRdmaDevs is set",
+				nameTmpl, rdmaDevNameIndexTmpl)
+		}
+	}
+	if _, err := regexp.Compile(conf.RdmaDevPattern); err != nil {
+		return fmt.Errorf("invalid rdmaDevPattern %q. %v", conf.RdmaDevPattern, err)
+	}
+	if conf.RdmaDevPort < 0 {
+		return fmt.Errorf("invalid rdmaDevPort %d", conf.RdmaDevPort)
+	}
+	if conf.StateDir != "" && !filepath.IsAbs(conf.StateDir) {
+		return fmt.Errorf("invalid stateDir %q, expecting an absolute path", conf.StateDir)
+	}
+	if timeout := conf.GetRdmaDevWaitTimeout(); timeout < 0 || timeout > maxRdmaDevWaitTimeout {
+		return fmt.Errorf("invalid rdmaDevWaitTimeout %v, expecting up to %v", timeout, maxRdmaDevWaitTimeout)
+	}
+	if conf.GUID != "" {
+		if _, err := rdma.ParseGUID(conf.GUID); err != nil {
+			return fmt.Errorf("invalid guid. %v", err)
+		}
+	}
+	if conf.Pkey != "" {
+		if _, err := parsePkey(conf.Pkey); err != nil {
+			return err
+		}
+	}
+	if conf.CheckIPoIBPkey && conf.Pkey == "" {
+		return errors.New("checkIPoIBPkey requires pkey")
+	}
+	return nil
+}
+
+// Parse InfiniBand partition key in hex e.g 0x8001, its base key must not be zero
+func parsePkey(pkey string) (uint16, error) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(pkey), "0x"), 16, 16)
+	if err != nil || value&^pkeyFullMember == 0 {
+		return 0, fmt.Errorf("invalid pkey %q, expecting a hex partition key e.g 0x8001", pkey)
+	}
+	return uint16(value), nil
+}
+
+// Whether the given partition key grants membership in the requested partition, partition keys grant membership
+// of the same partition and full membership is required only if requested
+func pkeyGrants(pkey, requested uint16) bool {
+	if pkey&^pkeyFullMember != requested&^pkeyFullMember {
+		return false
+	}
+	return requested&pkeyFullMember == 0 || pkey&pkeyFullMember != 0
+}
+
+// Ensure RDMA devices are members of the partition of the network configuration on each of their ports,
+// or on rdmaDevPort if set
+func (plugin *rdmaCniPlugin) ensureRdmaDevsPkey(rdmaDevs []string, conf *rdmatypes.RdmaNetConf) error {
+	// Validated in validateConf
+	pkey, _ := parsePkey(conf.Pkey)
+	for _, rdmaDev := range rdmaDevs {
+		ports := plugin.rdmaManager.GetRdmaDevPorts(rdmaDev)
+		if conf.RdmaDevPort != 0 {
+			ports = []string{strconv.Itoa(conf.RdmaDevPort)}
+		}
+		if len(ports) == 0 {
+			return fmt.Errorf("no ports found for RDMA device %s to check pkey %#04x", rdmaDev, pkey)
+		}
+		for _, port := range ports {
+			pkeys, err := plugin.rdmaManager.GetRdmaDevPkeys(rdmaDev, port)
+			if err != nil {
+				return err
+			}
+			if !slices.ContainsFunc(pkeys, func(p uint16) bool { return pkeyGrants(p, pkey) }) {
+				return fmt.Errorf("pkey %#04x is not in pkey table of RDMA device %s port %s", pkey, rdmaDev, port)
+			}
+		}
+	}
+	return nil
+}
+
+// Ensure container interface in previous result is an IPoIB interface on the partition of the network configuration
+func (plugin *rdmaCniPlugin) ensureIPoIBPkey(result *current.Result, ifName string, conf *rdmatypes.RdmaNetConf) error {
+	// Validated in validateConf
+	pkey, _ := parsePkey(conf.Pkey)
+	sandbox := getSandbox(result, ifName)
+	if sandbox == "" {
+		return fmt.Errorf("interface %s with a sandbox not found in prevResult", ifName)
+	}
+
+	sandboxNs, err := plugin.nsManager.GetNS(sandbox)
+	if err != nil {
+		return fmt.Errorf("failed to open network namespace %s. %v", sandbox, err)
+	}
+	defer sandboxNs.Close()
+
+	var ifPkey uint16
+	err = sandboxNs.Do(func(_ ns.NetNS) error {
+		ifPkey, err = plugin.rdmaManager.GetIPoIBPkey(ifName)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get pkey of interface %s. %v", ifName, err)
+	}
+	if !pkeyGrants(ifPkey, pkey) {
+		return fmt.Errorf("interface %s is on pkey %#04x, expecting pkey %#04x", ifName, ifPkey, pkey)
+	}
+	return nil
+}
+
+// Rename RDMA device in current namespace
+func (plugin *rdmaCniPlugin) setRdmaDevName(rdmaDev, name string) error {
+	if len(name) >= maxRdmaDevNameLen {
+		return fmt.Errorf("RDMA device name %q is too long", name)
+	}
+	return plugin.rdmaManager.RenameRdmaDev(rdmaDev, name)
+}
+
+// Rename RDMA device in current namespace according to the given name template, returns the new name
+func (plugin *rdmaCniPlugin) renameRdmaDev(rdmaDev, nameTmpl, ifName string) (string, error) {
+	name := strings.ReplaceAll(nameTmpl, rdmaDevNameIfnameTmpl, ifName)
+	if !strings.Contains(name, rdmaDevNameIndexTmpl) {
+		return name, plugin.setRdmaDevName(rdmaDev, name)
+	}
+
+	// RDMA device names are unique system wide, pick the first free index
+	for idx := 0; idx < maxRdmaDevNameIndex; idx++ {
+		candidate := strings.ReplaceAll(name, rdmaDevNameIndexTmpl, strconv.Itoa(idx))
+		err := plugin.setRdmaDevName(rdmaDev, candidate)
+		if !errors.Is(err, syscall.EEXIST) {
+			return candidate, err
+		}
+	}
+	return "", fmt.Errorf("no free RDMA device name matching %q", nameTmpl)
+}
+
+// Rename RDMA device in namespace according to the given name template, returns the new name
+func (plugin *rdmaCniPlugin) renameRdmaDevInNs(rdmaDev, nameTmpl, ifName, nsPath string) (string, error) {
+	log.Debug().Msgf("renaming RDMA device %s in namespace %s according to %q", rdmaDev, nsPath, nameTmpl)
+
+	targetNs, err := plugin.nsManager.GetNS(nsPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open network namespace %s: %v", nsPath, err)
+	}
+	defer targetNs.Close()
+
+	var newName string
+	err = targetNs.Do(func(_ ns.NetNS) error {
+		var renameErr error
+		newName, renameErr = plugin.renameRdmaDev(rdmaDev, nameTmpl, ifName)
+		return renameErr
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to rename RDMA device %s. %v", rdmaDev, err)
+	}
+	return newName, nil
+}
+
 // Move RDMA device to namespace
 func (plugin *rdmaCniPlugin) moveRdmaDevToNs(rdmaDev, nsPath string) error {
 	log.Debug().Msgf("moving RDMA device %s to namespace %s", rdmaDev, nsPath)
@@ -151,13 +541,32 @@ func (plugin *rdmaCniPlugin) moveRdmaDevToNs(rdmaDev, nsPath string) error {
 	return nil
 }
 
-// Move RDMA device from namespace to current (default) namespace
-func (plugin *rdmaCniPlugin) moveRdmaDevFromNs(rdmaDev, nsPath string) error {
+// Ensure RDMA device in current namespace is the physical device recorded in its cached state, by its node GUID.
+// RDMA devices cached without node GUID are not verified
+func (plugin *rdmaCniPlugin) verifyRdmaDev(rdmaDev string, cached rdmatypes.RdmaDevState) error {
+	if cached.NodeGUID == "" {
+		return nil
+	}
+	info, err := plugin.rdmaManager.GetRdmaDevInfo(rdmaDev)
+	if err != nil {
+		return err
+	}
+	if info.NodeGUID != cached.NodeGUID {
+		return fmt.Errorf("%w, RDMA device %s has node GUID %s, expecting %s",
+			errRdmaDevMismatch, rdmaDev, info.NodeGUID, cached.NodeGUID)
+	}
+	return nil
+}
+
+// Move RDMA device from namespace to current (default) namespace, restoring its original name.
+// RDMA device is verified to be the one recorded in its cached state before it is moved
+func (plugin *rdmaCniPlugin) moveRdmaDevFromNs(cached rdmatypes.RdmaDevState, nsPath string) error {
+	rdmaDev, sandboxRdmaDev := cached.ContainerRdmaDevName, cached.SandboxRdmaDevName
 	log.Debug().Msgf("INFO: moving RDMA device %s from namespace %s to default namespace", rdmaDev, nsPath)
 
 	sourceNs, err := plugin.nsManager.GetNS(nsPath)
 	if err != nil {
-		return fmt.Errorf("failed to open network namespace %s: %v", nsPath, err)
+		return fmt.Errorf("failed to open network namespace %s: %w", nsPath, err)
 	}
 	defer sourceNs.Close()
 
@@ -168,155 +577,1075 @@ func (plugin *rdmaCniPlugin) moveRdmaDevFromNs(rdmaDev, nsPath string) error {
 	defer targetNs.Close()
 
 	err = sourceNs.Do(func(_ ns.NetNS) error {
+		if verifyErr := plugin.verifyRdmaDev(rdmaDev, cached); verifyErr != nil {
+			return verifyErr
+		}
+		if rdmaDev != sandboxRdmaDev {
+			if renameErr := plugin.rdmaManager.RenameRdmaDev(rdmaDev, sandboxRdmaDev); renameErr != nil {
+				return renameErr
+			}
+			rdmaDev = sandboxRdmaDev
+		}
 		// Move RDMA device to default namespace
 		return plugin.rdmaManager.MoveRdmaDevToNs(rdmaDev, targetNs)
 	})
 	if err != nil {
-		return fmt.Errorf("failed to move RDMA device %s to default namespace. %v", rdmaDev, err)
+		return fmt.Errorf("failed to move RDMA device %s to default namespace. %w", rdmaDev, err)
 	}
 	return err
 }
 
-func (plugin *rdmaCniPlugin) CmdAdd(args *skel.CmdArgs) error {
-	log.Info().Msgf("RDMA-CNI: cmdAdd")
-	var err error
-	var conf *rdmatypes.RdmaNetConf
-	conf, err = plugin.parseConf(args.StdinData, args.Args)
+// Move RDMA devices to container namespace and rename them according to network configuration,
+// recording each completed step in undo
+func (plugin *rdmaCniPlugin) attachRdmaDevs(rdmaDevs []string, conf *rdmatypes.RdmaNetConf, args *skel.CmdArgs,
+	undo *undoStack) ([]rdmatypes.RdmaDevState, error) {
+	attached := make([]rdmatypes.RdmaDevState, 0, len(rdmaDevs))
+	for _, rdmaDev := range rdmaDevs {
+		// Node GUID identifies the RDMA device when it is detached, as its name may be reused meanwhile
+		info, err := plugin.rdmaManager.GetRdmaDevInfo(rdmaDev)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get attributes of RDMA device %s. %v", rdmaDev, err)
+		}
+		log.Info().Msgf("attaching RDMA device %s: node GUID %s, sys image GUID %s, firmware version %s, "+
+			"node type %q, ports %v", rdmaDev, info.NodeGUID, info.SysImageGUID, info.FirmwareVersion,
+			info.NodeType, info.Ports)
+		devState := rdmatypes.RdmaDevState{
+			SandboxRdmaDevName: rdmaDev, ContainerRdmaDevName: rdmaDev, NodeGUID: info.NodeGUID}
+
+		if err = plugin.moveRdmaDevToNs(rdmaDev, args.Netns); err != nil {
+			return nil, fmt.Errorf("failed to move RDMA device %s to namespace. %v", rdmaDev, err)
+		}
+		// Rename is undone first, RDMA device is moved back under its original name
+		moved := devState
+		undo.push(fmt.Sprintf("move of RDMA device %s to namespace %s", rdmaDev, args.Netns), func() error {
+			return plugin.moveRdmaDevFromNs(moved, args.Netns)
+		})
+
+		if conf.ContainerRdmaDevName != "" {
+			newName, err := plugin.renameRdmaDevInNs(rdmaDev, conf.ContainerRdmaDevName, args.IfName, args.Netns)
+			if err != nil {
+				return nil, err
+			}
+			undo.push(fmt.Sprintf("rename of RDMA device %s to %s", rdmaDev, newName), func() error {
+				// Original name contains no templates
+				_, err := plugin.renameRdmaDevInNs(newName, rdmaDev, args.IfName, args.Netns)
+				return err
+			})
+			devState.ContainerRdmaDevName = newName
+		}
+		attached = append(attached, devState)
+	}
+	return attached, nil
+}
+
+// Set node and port GUID of the VF DeviceID to the GUID of the network configuration, recording the completed step
+// in undo. Returns the previous node GUID of the VF: the one recorded in the cache entry pRef if a previous ADD of
+// the attachment already set GUID, otherwise as reported by the given RDMA device of the VF
+func (plugin *rdmaCniPlugin) setVfGUID(conf *rdmatypes.RdmaNetConf, rdmaDev string, pRef cache.StateRef,
+	undo *undoStack) (string, error) {
+	if !utils.IsPCIAddress(conf.DeviceID) {
+		return "", fmt.Errorf("guid requires deviceID to be a VF PCI address, got %q", conf.DeviceID)
+	}
+	// Validated in validateConf
+	guid, _ := rdma.ParseGUID(conf.GUID)
+	prevNodeGUID := plugin.getCachedPrevGUID(conf, pRef)
+	if prevNodeGUID == "" {
+		info, err := plugin.rdmaManager.GetRdmaDevInfo(rdmaDev)
+		if err != nil {
+			return "", fmt.Errorf("failed to get node GUID of RDMA device %s. %v", rdmaDev, err)
+		}
+		prevNodeGUID = info.NodeGUID
+	}
+	prevGUID, err := rdma.ParseGUID(prevNodeGUID)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("invalid previous GUID of VF %s. %v", conf.DeviceID, err)
 	}
-	if conf.Args.CNI.Debug {
-		setDebugMode()
+	if err = plugin.rdmaManager.SetVfGUID(conf.DeviceID, guid); err != nil {
+		return "", fmt.Errorf("failed to set GUID of VF %s. %v", conf.DeviceID, err)
 	}
+	undo.push(fmt.Sprintf("set of GUID of VF %s to %s", conf.DeviceID, guid), func() error {
+		return plugin.rdmaManager.SetVfGUID(conf.DeviceID, prevGUID)
+	})
+	return prevGUID.String(), nil
+}
 
-	log.Debug().Msgf("cmdAdd: args: %+v ", args)
+// Get previous GUID of the VF DeviceID recorded in the cache entry pRef, if GUID was set by a previous ADD of the
+// attachment. Its current GUID is then the one set by that ADD
+func (plugin *rdmaCniPlugin) getCachedPrevGUID(conf *rdmatypes.RdmaNetConf, pRef cache.StateRef) string {
+	rdmaState := rdmatypes.RdmaNetState{}
+	if err := plugin.stateCache.Load(pRef, &rdmaState); err != nil || rdmaState.DeviceID != conf.DeviceID {
+		return ""
+	}
+	return rdmaState.PrevGUID
+}
 
-	// Ensure RDMA-CNI was called as part of a chain, and parse PrevResult
-	if conf.RawPrevResult == nil {
-		return fmt.Errorf("RDMA-CNI is expected to be called as part of a plugin chain")
+// Restore GUID the VF of the given state had before GUID was set, under node lock. Previous port GUID is not recorded,
+// so both node and port GUID are reset to the previous node GUID
+func (plugin *rdmaCniPlugin) restoreVfGUIDLocked(state *rdmatypes.RdmaNetState) error {
+	if state.PrevGUID == "" {
+		return nil
 	}
-	err = cniversion.ParsePrevResult(&conf.NetConf)
+	prevGUID, err := rdma.ParseGUID(state.PrevGUID)
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid previous GUID of VF %s. %v", state.DeviceID, err)
 	}
-	result, err := current.NewResultFromResult(conf.PrevResult)
+	unlockNode, err := plugin.locker.LockNode()
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to lock node. %v", err)
 	}
-	log.Debug().Msgf("prev results: %+v", result)
+	defer unlockNode()
+	if err = plugin.rdmaManager.SetVfGUID(state.DeviceID, prevGUID); err != nil {
+		return fmt.Errorf("failed to restore GUID of VF %s to %s. %v", state.DeviceID, prevGUID, err)
+	}
+	return nil
+}
 
-	// Ensure RDMA subsystem mode
-	err = plugin.ensureRdmaSystemMode()
+// Ensure RDMA devices are visible in container namespace without moving them, as in shared RDMA subsystem
+// namespace awareness mode RDMA devices are visible in all namespaces
+func (plugin *rdmaCniPlugin) shareRdmaDevs(rdmaDevs []string, nsPath string) ([]rdmatypes.RdmaDevState, error) {
+	containerNs, err := plugin.nsManager.GetNS(nsPath)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to open network namespace %s. %v", nsPath, err)
 	}
+	defer containerNs.Close()
 
-	// Delegate plugin may not add Device ID to the network configuration, if so,
-	// attempt to derive it from PrevResult Mac address with some sysfs voodoo
-	if conf.DeviceID == "" {
-		if conf.DeviceID, err = plugin.deriveDeviceIDFromResult(result); err != nil {
-			return err
+	shared := make([]rdmatypes.RdmaDevState, 0, len(rdmaDevs))
+	for _, rdmaDev := range rdmaDevs {
+		visible, err := plugin.isRdmaDevInNs(rdmaDev, containerNs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get RDMA devices in namespace %s. %v", nsPath, err)
 		}
+		if !visible {
+			return nil, fmt.Errorf("RDMA device %s is not visible in namespace %s, "+
+				"RDMA subsystem namespace awareness mode is expected to be %s", rdmaDev, nsPath, rdma.RdmaSysModeShared)
+		}
+		shared = append(shared, rdmatypes.RdmaDevState{SandboxRdmaDevName: rdmaDev, ContainerRdmaDevName: rdmaDev})
 	}
+	log.Warn().Msgf("RDMA devices %v are shared with namespace %s, RDMA isolation is not enforced", rdmaDevs, nsPath)
+	return shared, nil
+}
 
-	// Move RDMA device to container namespace
-	rdmaDev, err := plugin.getRDMADevice(conf.DeviceID)
-	if err != nil {
-		return fmt.Errorf("failed to get RDMA device for device ID %s: %w", conf.DeviceID, err)
-	}
-
-	err = plugin.moveRdmaDevToNs(rdmaDev, args.Netns)
+// Same as detachRdmaDevs, under node lock
+func (plugin *rdmaCniPlugin) detachRdmaDevsLocked(rdmaDevs []rdmatypes.RdmaDevState, nsPath string) error {
+	unlockNode, err := plugin.locker.LockNode()
 	if err != nil {
-		return fmt.Errorf("failed to move RDMA device %s to namespace. %v", rdmaDev, err)
+		return fmt.Errorf("failed to lock node. %v", err)
 	}
+	defer unlockNode()
+	return plugin.detachRdmaDevs(rdmaDevs, nsPath)
+}
 
-	// Save RDMA state
-	state := rdmatypes.NewRdmaNetState()
-	state.DeviceID = conf.DeviceID
-	state.SandboxRdmaDevName = rdmaDev
-	state.ContainerRdmaDevName = rdmaDev
-	pRef := plugin.stateCache.GetStateRef(conf.Name, args.ContainerID, args.IfName)
-	err = plugin.stateCache.Save(pRef, &state)
-	if err != nil {
-		// Move RDMA dev back to current namespace
-		restoreErr := plugin.moveRdmaDevFromNs(state.ContainerRdmaDevName, args.Netns)
-		if restoreErr != nil {
-			return fmt.Errorf(
-				"save to cache failed %v, failed while restoring namespace for RDMA device %s. %v",
-				err, rdmaDev, restoreErr)
+// Move RDMA devices from namespace to current (default) namespace, restoring their original names.
+// RDMA devices already moved back by a previous DEL are skipped
+func (plugin *rdmaCniPlugin) detachRdmaDevs(rdmaDevs []rdmatypes.RdmaDevState, nsPath string) error {
+	var errs []error
+	for _, rdmaDev := range rdmaDevs {
+		detached, err := plugin.isRdmaDevDetached(rdmaDev)
+		if err == nil && !detached {
+			err = plugin.moveRdmaDevFromNs(rdmaDev, nsPath)
+		}
+		if errors.Is(err, errRdmaDevMismatch) {
+			// Not ours to move, left in namespace
+			log.Error().Msgf("not moving RDMA device %s from namespace %s. %v", rdmaDev.ContainerRdmaDevName, nsPath, err)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
 		}
-		return err
 	}
-	return types.PrintResult(result, conf.CNIVersion)
-}
-
-func (plugin *rdmaCniPlugin) CmdCheck(args *skel.CmdArgs) error {
-	log.Info().Msgf("cmdCheck() not Implemented. args: %v ", args)
-	return nil
+	return errors.Join(errs...)
 }
 
-func (plugin *rdmaCniPlugin) CmdDel(args *skel.CmdArgs) error {
-	log.Info().Msgf("RDMA-CNI: cmdDel")
-	conf, err := plugin.parseConf(args.StdinData, args.Args)
+// Check whether RDMA device is already in current (default) namespace, under its original or container name,
+// restoring its original name if needed
+func (plugin *rdmaCniPlugin) isRdmaDevDetached(rdmaDev rdmatypes.RdmaDevState) (bool, error) {
+	currNs, err := plugin.nsManager.GetCurrentNS()
 	if err != nil {
-		return err
-	}
-	if conf.Args.CNI.Debug {
-		setDebugMode()
+		return false, fmt.Errorf("failed to open current network namespace: %v", err)
 	}
-	log.Debug().Msgf("CmdDel() args: %v ", args)
+	defer currNs.Close()
 
-	// Container already exited, so no Namespace. if no Namespace, we got nothing to clean.
-	// this may happen in Infra containers as described in https://github.com/kubernetes/kubernetes/pull/35240
-	if args.Netns == "" {
-		return nil
+	for _, name := range slices.Compact([]string{rdmaDev.SandboxRdmaDevName, rdmaDev.ContainerRdmaDevName}) {
+		inCurrent, err := plugin.isRdmaDevInNs(name, currNs)
+		if err != nil {
+			return false, fmt.Errorf("failed to get RDMA devices in current network namespace. %v", err)
+		}
+		if !inCurrent {
+			continue
+		}
+		if err = plugin.verifyRdmaDev(name, rdmaDev); err != nil {
+			return false, err
+		}
+		log.Info().Msgf("RDMA device %s is already in default namespace", name)
+		if name != rdmaDev.SandboxRdmaDevName {
+			return true, plugin.rdmaManager.RenameRdmaDev(name, rdmaDev.SandboxRdmaDevName)
+		}
+		return true, nil
 	}
+	return false, nil
+}
 
-	// Load RDMA device state from cache
-	rdmaState := rdmatypes.RdmaNetState{}
-	pRef := plugin.stateCache.GetStateRef(conf.Name, args.ContainerID, args.IfName)
-	err = plugin.stateCache.Load(pRef, &rdmaState)
-	if err != nil {
-		log.Warn().Msgf("failed to load cache entry(%q). it may have been deleted by a previous CMD_DEL call. %v", pRef, err)
+// Ensure the RDMA device or device ID of the network configuration is not attached to another live container
+func (plugin *rdmaCniPlugin) ensureDeviceNotAttached(conf *rdmatypes.RdmaNetConf, pRef cache.StateRef) error {
+	var refs []cache.StateRef
+	var err error
+	var device string
+	switch {
+	case conf.RdmaDevice != "":
+		device = "RDMA device " + conf.RdmaDevice
+		refs, err = plugin.stateCache.ListByRdmaDev(conf.RdmaDevice)
+	case conf.DeviceID != "":
+		device = "device " + conf.DeviceID
+		refs, err = plugin.stateCache.ListByDeviceID(conf.DeviceID)
+	default:
 		return nil
 	}
-
-	// Move RDMA device to default namespace
-	err = plugin.moveRdmaDevFromNs(rdmaState.ContainerRdmaDevName, args.Netns)
 	if err != nil {
-		return fmt.Errorf(
-			"failed to restore RDMA device %s to default namespace. %v", rdmaState.ContainerRdmaDevName, err)
+		return fmt.Errorf("failed to look up attachments of %s. %v", device, err)
 	}
+	return plugin.ensureNoLiveAttachment(device, refs, pRef)
+}
 
-	err = plugin.stateCache.Delete(pRef)
-	if err != nil {
-		log.Warn().Msgf("failed to delete cache entry(%q). %v", pRef, err)
+// Ensure RDMA devices are not attached to another live container
+func (plugin *rdmaCniPlugin) ensureRdmaDevsNotAttached(rdmaDevs []string, pRef cache.StateRef) error {
+	for _, rdmaDev := range rdmaDevs {
+		refs, err := plugin.stateCache.ListByRdmaDev(rdmaDev)
+		if err != nil {
+			return fmt.Errorf("failed to look up attachments of RDMA device %s. %v", rdmaDev, err)
+		}
+		if err = plugin.ensureNoLiveAttachment("RDMA device "+rdmaDev, refs, pRef); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// getRDMADevice returns the first RDMA device found for the given deviceID.
-func (plugin *rdmaCniPlugin) getRDMADevice(deviceID string) (string, error) {
-	var rdmaDevs []string
-	if utils.IsPCIAddress(deviceID) {
-		rdmaDevs = plugin.rdmaManager.GetRdmaDevsForPciDev(deviceID)
-		if len(rdmaDevs) == 0 {
-			return "", errors.New("no RDMA devices found")
+// Ensure none of the attachments of device in cache, other than pRef, is live. Attachments which are provably gone
+// are left to be released by DEL or GC
+func (plugin *rdmaCniPlugin) ensureNoLiveAttachment(device string, refs []cache.StateRef, pRef cache.StateRef) error {
+	for _, ref := range refs {
+		if ref == pRef {
+			continue
 		}
-	} else {
-		rdmaDevs = plugin.rdmaManager.GetRdmaDevsForAuxDev(deviceID)
-		if len(rdmaDevs) == 0 {
-			return "", errors.New("no RDMA devices found")
+		owner := rdmatypes.RdmaNetState{}
+		if err := plugin.stateCache.Load(ref, &owner); err != nil {
+			log.Warn().Msgf("failed to load cache entry(%q), skipping. %v", ref, err)
+			continue
+		}
+		if plugin.isAttachmentGone(&owner) {
+			log.Warn().Msgf("%s is attached to container %q whose network namespace %q is gone, cache entry(%q)",
+				device, owner.ContainerID, owner.Netns, ref)
+			continue
 		}
+		return fmt.Errorf("%s is already attached to container %q (network %q, interface %q, namespace %q)",
+			device, owner.ContainerID, owner.Network, owner.IfName, owner.Netns)
 	}
+	return nil
+}
 
-	if len(rdmaDevs) != 1 {
-		// Expecting exactly one RDMA device
-		return "", fmt.Errorf(
-			"discovered more than one RDMA device %v. Unsupported state", rdmaDevs)
+// Whether the attachment of the cached state is provably gone, i.e its network namespace is gone, in which case
+// the kernel already returned its RDMA devices to the default namespace. States cached by version 1.0 do not record
+// the network namespace, their attachment is gone if their RDMA devices are back in current (default) namespace.
+// Requires RDMA subsystem exclusive mode, where RDMA devices are only visible in the namespace they are in
+func (plugin *rdmaCniPlugin) isAttachmentGone(state *rdmatypes.RdmaNetState) bool {
+	if state.Netns != "" {
+		return plugin.isNetNsGone(state.Netns)
 	}
-
-	return rdmaDevs[0], nil
+	rdmaDevs := state.GetRdmaDevs()
+	if state.IsShared() || len(rdmaDevs) == 0 {
+		return false
+	}
+	currRdmaDevs, err := plugin.rdmaManager.GetRdmaDevs()
+	if err != nil {
+		log.Warn().Msgf("failed to get RDMA devices in current network namespace. %v", err)
+		return false
+	}
+	for _, rdmaDev := range rdmaDevs {
+		// Returned RDMA devices keep their container name, see restoreStaleRdmaDev
+		if !slices.Contains(currRdmaDevs, rdmaDev.ContainerRdmaDevName) &&
+			!slices.Contains(currRdmaDevs, rdmaDev.SandboxRdmaDevName) {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether the network namespace is provably gone, unknown network namespaces are assumed to exist
+func (plugin *rdmaCniPlugin) isNetNsGone(nsPath string) bool {
+	if nsPath == "" {
+		return false
+	}
+	netNs, err := plugin.nsManager.GetNS(nsPath)
+	if err != nil {
+		var notExistErr ns.NSPathNotExistErr
+		var notNsErr ns.NSPathNotNSErr
+		return errors.As(err, &notExistErr) || errors.As(err, &notNsErr)
+	}
+	_ = netNs.Close()
+	return false
+}
+
+// Get RDMA devices cached for the attachment by a previous ADD, if they are still attached to the container
+// network namespace, nil otherwise
+func (plugin *rdmaCniPlugin) getAttachedRdmaDevs(
+	conf *rdmatypes.RdmaNetConf, args *skel.CmdArgs, pRef cache.StateRef) []rdmatypes.RdmaDevState {
+	rdmaState := rdmatypes.RdmaNetState{}
+	if err := plugin.stateCache.Load(pRef, &rdmaState); err != nil {
+		return nil
+	}
+	shared := conf.Mode == rdmatypes.RdmaNetModeShared
+	if rdmaState.Netns != args.Netns || rdmaState.IsShared() != shared ||
+		(conf.DeviceID != "" && rdmaState.DeviceID != conf.DeviceID) ||
+		(conf.RdmaDevice != "" && !rdmaState.HasRdmaDev(conf.RdmaDevice)) {
+		log.Debug().Msgf("cache entry(%q) does not match the attachment, ignoring it", pRef)
+		return nil
+	}
+
+	checkRdmaDev := plugin.checkRdmaDevInNs
+	if shared {
+		checkRdmaDev = plugin.checkRdmaDevVisibleInNs
+	}
+	rdmaDevs := rdmaState.GetRdmaDevs()
+	for _, rdmaDev := range rdmaDevs {
+		if err := checkRdmaDev(rdmaDev.ContainerRdmaDevName, args.Netns); err != nil {
+			log.Debug().Msgf("RDMA devices of cache entry(%q) are not attached, ignoring it. %v", pRef, err)
+			return nil
+		}
+	}
+	log.Info().Msgf("RDMA devices %+v are already attached to namespace %s", rdmaDevs, args.Netns)
+	return rdmaDevs
+}
+
+// Get RDMA devices to attach to container, attach them under node lock and save their state, recording each
+// completed step in undo. Completed steps are undone on failure
+func (plugin *rdmaCniPlugin) attachRdmaDevsAndSaveState(conf *rdmatypes.RdmaNetConf, args *skel.CmdArgs,
+	pRef cache.StateRef, undo *undoStack) ([]rdmatypes.RdmaDevState, error) {
+	shared := conf.Mode == rdmatypes.RdmaNetModeShared
+	// RDMA devices attached to another container are not visible in current namespace, check the
+	// configured device before waiting for them to fail fast with a descriptive error
+	if !shared {
+		if err := plugin.ensureDeviceNotAttached(conf, pRef); err != nil {
+			return nil, err
+		}
+	}
+	plugin.waitRdmaDevices(conf)
+	unlockNode, err := plugin.locker.LockNode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock node. %v", err)
+	}
+	defer unlockNode()
+
+	rdmaDevs, err := plugin.getRdmaDevices(conf)
+	if err != nil {
+		if conf.RdmaDevice != "" {
+			return nil, fmt.Errorf("failed to get RDMA device %s: %w", conf.RdmaDevice, err)
+		}
+		return nil, fmt.Errorf("failed to get RDMA device for device ID %s: %w", conf.DeviceID, err)
+	}
+	if !shared && conf.RdmaDevice == "" {
+		if err = plugin.ensureRdmaDevsNotAttached(rdmaDevs, pRef); err != nil {
+			return nil, err
+		}
+	}
+	if conf.Pkey != "" {
+		if err = plugin.ensureRdmaDevsPkey(rdmaDevs, conf); err != nil {
+			return nil, err
+		}
+	}
+	// GUID is set while RDMA devices are still in current namespace, where their VF is
+	var prevGUID string
+	if conf.GUID != "" {
+		if prevGUID, err = plugin.setVfGUID(conf, rdmaDevs[0], pRef, undo); err != nil {
+			return nil, err
+		}
+	}
+
+	// Move RDMA devices to container namespace, or ensure container can access them in shared mode
+	var attachedDevs []rdmatypes.RdmaDevState
+	if shared {
+		attachedDevs, err = plugin.shareRdmaDevs(rdmaDevs, args.Netns)
+	} else {
+		attachedDevs, err = plugin.attachRdmaDevs(rdmaDevs, conf, args, undo)
+	}
+	if err != nil {
+		return nil, undo.rollback(err)
+	}
+
+	// Save RDMA state
+	state := rdmatypes.NewRdmaNetState()
+	state.DeviceID = conf.DeviceID
+	state.SetRdmaDevs(attachedDevs)
+	state.Network = conf.Name
+	state.ContainerID = args.ContainerID
+	state.IfName = args.IfName
+	state.Netns = args.Netns
+	if shared {
+		state.Mode = rdmatypes.RdmaNetModeShared
+	}
+	if conf.GUID != "" {
+		state.GUID = conf.GUID
+		state.PrevGUID = prevGUID
+	}
+	if err = plugin.stateCache.Save(pRef, &state); err != nil {
+		return nil, undo.rollback(fmt.Errorf("save to cache failed. %v", err))
+	}
+	undo.push(fmt.Sprintf("save of cache entry(%q)", pRef), func() error {
+		return plugin.stateCache.Delete(pRef)
+	})
+	return attachedDevs, nil
+}
+
+// Undo completed steps under node lock
+func (plugin *rdmaCniPlugin) rollbackLocked(undo *undoStack, err error) error {
+	unlockNode, lockErr := plugin.locker.LockNode()
+	if lockErr != nil {
+		return fmt.Errorf("%v, failed to lock node to undo completed steps. %v", err, lockErr)
+	}
+	defer unlockNode()
+	return undo.rollback(err)
+}
+
+func (plugin *rdmaCniPlugin) CmdAdd(args *skel.CmdArgs) (err error) {
+	log.Info().Msgf("RDMA-CNI: cmdAdd")
+	var conf *rdmatypes.RdmaNetConf
+	conf, err = plugin.parseConf(args.StdinData, args.Args)
+	if err != nil {
+		return err
+	}
+	if conf.Args.CNI.Debug {
+		setDebugMode()
+	}
+
+	log.Debug().Msgf("cmdAdd: args: %+v ", args)
+	plugin.initStateCache(conf)
+	if err = plugin.ensureStateCachePersistent(conf); err != nil {
+		return types.NewError(types.ErrInvalidNetworkConfig, "invalid stateDir", err.Error())
+	}
+
+	// Ensure RDMA-CNI was called as part of a chain, and parse PrevResult
+	if conf.RawPrevResult == nil {
+		return fmt.Errorf("RDMA-CNI is expected to be called as part of a plugin chain")
+	}
+	err = cniversion.ParsePrevResult(&conf.NetConf)
+	if err != nil {
+		return err
+	}
+	result, err := current.NewResultFromResult(conf.PrevResult)
+	if err != nil {
+		return err
+	}
+	log.Debug().Msgf("prev results: %+v", result)
+
+	// IPoIB interface on the partition is expected to be created by the previous plugin
+	if conf.CheckIPoIBPkey {
+		if err = plugin.ensureIPoIBPkey(result, args.IfName, conf); err != nil {
+			return err
+		}
+	}
+
+	// Ensure RDMA subsystem mode, RDMA devices are not moved in shared mode.
+	// Mode is never switched here, the container network namespace already exists so the kernel would refuse it
+	shared := conf.Mode == rdmatypes.RdmaNetModeShared
+	if !shared {
+		if err = plugin.ensureRdmaSystemMode(false); err != nil {
+			if conf.AutoExclusiveMode {
+				return fmt.Errorf("%v, autoExclusiveMode can only switch it from STATUS before any pod exists", err)
+			}
+			return err
+		}
+	}
+
+	// Delegate plugin may not add Device ID to the network configuration, if so, attempt to resolve it.
+	// Not required if RDMA device is explicitly provided
+	if conf.DeviceID == "" && conf.RdmaDevice == "" {
+		if conf.DeviceID, err = plugin.resolveDeviceID(conf, args, result); err != nil {
+			return err
+		}
+	}
+
+	// Serialize operations on the same attachment with concurrent CNI invocations
+	pRef := plugin.stateCache.GetStateRef(conf.Name, args.ContainerID, args.IfName)
+	unlockRef, err := plugin.locker.LockRef(pRef)
+	if err != nil {
+		return fmt.Errorf("failed to lock cache entry(%q). %v", pRef, err)
+	}
+	defer unlockRef()
+
+	// Steps completed by this ADD are undone if any later step fails
+	undo := &undoStack{}
+	defer func() {
+		if err != nil && !undo.empty() {
+			err = plugin.rollbackLocked(undo, err)
+		}
+	}()
+
+	// ADD may be retried after a failure following a successful attachment
+	attachedDevs := plugin.getAttachedRdmaDevs(conf, args, pRef)
+	if attachedDevs == nil {
+		if attachedDevs, err = plugin.attachRdmaDevsAndSaveState(conf, args, pRef, undo); err != nil {
+			return err
+		}
+	}
+
+	// RDMA devices shared with the container are reported as host interfaces, as they are not isolated
+	sandbox := args.Netns
+	if shared {
+		sandbox = ""
+	}
+	for _, rdmaDev := range attachedDevs {
+		addRdmaDevInterface(result, newRdmaDevInterface(rdmaDev.ContainerRdmaDevName, conf.DeviceID, sandbox))
+	}
+	return types.PrintResult(result, conf.CNIVersion)
+}
+
+// Check if RDMA device is present in the given network namespace
+func (plugin *rdmaCniPlugin) isRdmaDevInNs(rdmaDev string, netNs ns.NetNS) (bool, error) {
+	found := false
+	err := netNs.Do(func(_ ns.NetNS) error {
+		rdmaDevs, err := plugin.rdmaManager.GetRdmaDevs()
+		if err != nil {
+			return err
+		}
+		found = slices.Contains(rdmaDevs, rdmaDev)
+		return nil
+	})
+	return found, err
+}
+
+// Ensure RDMA device is visible in container namespace
+func (plugin *rdmaCniPlugin) checkRdmaDevVisibleInNs(rdmaDev, nsPath string) error {
+	containerNs, err := plugin.nsManager.GetNS(nsPath)
+	if err != nil {
+		return types.NewError(types.ErrInvalidNetNS,
+			fmt.Sprintf("failed to open network namespace %s", nsPath), err.Error())
+	}
+	defer containerNs.Close()
+
+	inContainer, err := plugin.isRdmaDevInNs(rdmaDev, containerNs)
+	if err != nil {
+		return types.NewError(types.ErrInternal,
+			fmt.Sprintf("failed to get RDMA devices in namespace %s", nsPath), err.Error())
+	}
+	if !inContainer {
+		return types.NewError(types.ErrInternal,
+			fmt.Sprintf("RDMA device %s not found in namespace %s", rdmaDev, nsPath), "")
+	}
+	return nil
+}
+
+// Ensure RDMA device resides in container namespace and not in current (default) namespace
+func (plugin *rdmaCniPlugin) checkRdmaDevInNs(rdmaDev, nsPath string) error {
+	if err := plugin.checkRdmaDevVisibleInNs(rdmaDev, nsPath); err != nil {
+		return err
+	}
+
+	currNs, err := plugin.nsManager.GetCurrentNS()
+	if err != nil {
+		return types.NewError(types.ErrInternal, "failed to open current network namespace", err.Error())
+	}
+	defer currNs.Close()
+
+	inCurrent, err := plugin.isRdmaDevInNs(rdmaDev, currNs)
+	if err != nil {
+		return types.NewError(types.ErrInternal,
+			"failed to get RDMA devices in current network namespace", err.Error())
+	}
+	if inCurrent {
+		return types.NewError(types.ErrInternal,
+			fmt.Sprintf("RDMA device %s found in default namespace, expected it only in namespace %s",
+				rdmaDev, nsPath), "")
+	}
+	return nil
+}
+
+// Ensure PrevResult contains the container interface RDMA device was attached for
+func (plugin *rdmaCniPlugin) validatePrevResult(result *current.Result, args *skel.CmdArgs) error {
+	for _, iface := range result.Interfaces {
+		if iface.Name == args.IfName && iface.Sandbox != "" {
+			return nil
+		}
+	}
+	return types.NewError(types.ErrInvalidNetworkConfig,
+		fmt.Sprintf("prevResult does not contain container interface %s", args.IfName), "")
+}
+
+func (plugin *rdmaCniPlugin) CmdCheck(args *skel.CmdArgs) error {
+	log.Info().Msgf("RDMA-CNI: cmdCheck")
+	conf, err := plugin.parseConf(args.StdinData, args.Args)
+	if err != nil {
+		return types.NewError(types.ErrDecodingFailure, "failed to parse network configuration", err.Error())
+	}
+	if conf.Args.CNI.Debug {
+		setDebugMode()
+	}
+	log.Debug().Msgf("CmdCheck() args: %v ", args)
+	plugin.initStateCache(conf)
+
+	// Ensure RDMA-CNI was called as part of a chain, and validate PrevResult
+	if conf.RawPrevResult == nil {
+		return types.NewError(types.ErrInvalidNetworkConfig,
+			"RDMA-CNI is expected to be called as part of a plugin chain", "")
+	}
+	if err = cniversion.ParsePrevResult(&conf.NetConf); err != nil {
+		return types.NewError(types.ErrDecodingFailure, "failed to parse prevResult", err.Error())
+	}
+	result, err := current.NewResultFromResult(conf.PrevResult)
+	if err != nil {
+		return types.NewError(types.ErrDecodingFailure, "failed to convert prevResult", err.Error())
+	}
+	if err = plugin.validatePrevResult(result, args); err != nil {
+		return err
+	}
+
+	// Load RDMA device state from cache
+	rdmaState := rdmatypes.RdmaNetState{}
+	pRef := plugin.stateCache.GetStateRef(conf.Name, args.ContainerID, args.IfName)
+	unlockRef, err := plugin.locker.LockRef(pRef)
+	if err != nil {
+		return types.NewError(types.ErrTryAgainLater, fmt.Sprintf("failed to lock cache entry(%q)", pRef), err.Error())
+	}
+	defer unlockRef()
+	if err = plugin.stateCache.Load(pRef, &rdmaState); err != nil {
+		return types.NewError(types.ErrUnknownContainer,
+			fmt.Sprintf("failed to load cache entry(%q)", pRef), err.Error())
+	}
+	if conf.DeviceID != "" && conf.DeviceID != rdmaState.DeviceID {
+		return types.NewError(types.ErrInvalidNetworkConfig,
+			fmt.Sprintf("DeviceID %s does not match cached DeviceID %s", conf.DeviceID, rdmaState.DeviceID), "")
+	}
+	if conf.RdmaDevice != "" && !slices.ContainsFunc(rdmaState.GetRdmaDevs(), func(dev rdmatypes.RdmaDevState) bool {
+		return dev.SandboxRdmaDevName == conf.RdmaDevice
+	}) {
+		return types.NewError(types.ErrInvalidNetworkConfig,
+			fmt.Sprintf("RDMA device %s does not match cached RDMA devices %+v",
+				conf.RdmaDevice, rdmaState.GetRdmaDevs()), "")
+	}
+
+	if shared := conf.Mode == rdmatypes.RdmaNetModeShared; shared != rdmaState.IsShared() {
+		return types.NewError(types.ErrInvalidNetworkConfig,
+			fmt.Sprintf("mode %q does not match mode of cached RDMA devices", conf.Mode), "")
+	}
+
+	// RDMA devices shared with the container are reported as host interfaces
+	sandbox := args.Netns
+	checkRdmaDev := plugin.checkRdmaDevInNs
+	if rdmaState.IsShared() {
+		sandbox = ""
+		checkRdmaDev = plugin.checkRdmaDevVisibleInNs
+	}
+	for _, rdmaDev := range rdmaState.GetRdmaDevs() {
+		for _, iface := range result.Interfaces {
+			if iface.Name == rdmaDev.ContainerRdmaDevName && iface.Sandbox != sandbox {
+				return types.NewError(types.ErrInvalidNetworkConfig,
+					fmt.Sprintf("prevResult reports RDMA device %s in sandbox %q, expected %q",
+						iface.Name, iface.Sandbox, sandbox), "")
+			}
+		}
+		if err = checkRdmaDev(rdmaDev.ContainerRdmaDevName, args.Netns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (plugin *rdmaCniPlugin) CmdDel(args *skel.CmdArgs) error {
+	log.Info().Msgf("RDMA-CNI: cmdDel")
+	conf, err := plugin.parseConf(args.StdinData, args.Args)
+	if err != nil {
+		return err
+	}
+	if conf.Args.CNI.Debug {
+		setDebugMode()
+	}
+	log.Debug().Msgf("CmdDel() args: %v ", args)
+	plugin.initStateCache(conf)
+
+	// Load RDMA device state from cache
+	rdmaState := rdmatypes.RdmaNetState{}
+	pRef := plugin.stateCache.GetStateRef(conf.Name, args.ContainerID, args.IfName)
+	unlockRef, err := plugin.locker.LockRef(pRef)
+	if err != nil {
+		return fmt.Errorf("failed to lock cache entry(%q). %v", pRef, err)
+	}
+	defer unlockRef()
+	err = plugin.stateCache.Load(pRef, &rdmaState)
+	if err != nil {
+		if errors.Is(err, cache.ErrCorruptedState) {
+			// Corrupted cache entry was moved aside, so it is not retried
+			log.Error().Msgf("failed to load corrupted cache entry(%q), restoring RDMA devices of the network "+
+				"configuration. %v", pRef, err)
+			plugin.restoreUncachedRdmaDevs(conf, args.Netns)
+			plugin.removeRefLock(pRef)
+			return nil
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Warn().Msgf("cache entry(%q) does not exist. it may have been deleted by a previous CMD_DEL call", pRef)
+			plugin.restoreUncachedRdmaDevs(conf, args.Netns)
+			plugin.removeRefLock(pRef)
+			return nil
+		}
+		// e.g unsupported state version or permission error, the cache entry is kept for inspection
+		log.Warn().Msgf("failed to load cache entry(%q), restoring RDMA devices of the network configuration. %v",
+			pRef, err)
+		plugin.restoreUncachedRdmaDevs(conf, args.Netns)
+		return nil
+	}
+
+	// Container already exited, so no Namespace. this may happen in Infra containers as described in
+	// https://github.com/kubernetes/kubernetes/pull/35240, fall back to the namespace the RDMA devices were moved to
+	nsPath := args.Netns
+	if nsPath == "" {
+		nsPath = rdmaState.Netns
+	}
+
+	// Move RDMA devices to default namespace, RDMA devices shared with the container were never moved.
+	// If the namespace is gone, the kernel already returned them to the default namespace
+	if !rdmaState.IsShared() {
+		if nsPath == "" || plugin.isNetNsGone(nsPath) {
+			err = plugin.restoreStaleRdmaDevs(rdmaState.GetRdmaDevs(), nsPath)
+		} else {
+			err = plugin.detachRdmaDevsLocked(rdmaState.GetRdmaDevs(), nsPath)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore RDMA devices to default namespace. %v", err)
+		}
+	}
+	if err = plugin.restoreVfGUIDLocked(&rdmaState); err != nil {
+		return err
+	}
+
+	err = plugin.stateCache.Delete(pRef)
+	if err != nil {
+		log.Warn().Msgf("failed to delete cache entry(%q). %v", pRef, err)
+		return nil
+	}
+	plugin.removeRefLock(pRef)
+	return nil
+}
+
+// Remove lock file of a deleted cache entry, while still holding its lock
+func (plugin *rdmaCniPlugin) removeRefLock(ref cache.StateRef) {
+	if err := plugin.locker.RemoveRefLock(ref); err != nil {
+		log.Warn().Msgf("failed to remove lock of cache entry(%q). %v", ref, err)
+	}
+}
+
+// Restore RDMA devices of the network configuration whose cache entry does not exist or cannot be loaded.
+// RDMA devices looked up by device ID are visible only in the namespace they are in, so they are not recovered,
+// only verified to be in default namespace
+func (plugin *rdmaCniPlugin) restoreUncachedRdmaDevs(conf *rdmatypes.RdmaNetConf, nsPath string) {
+	switch {
+	case conf.RdmaDevice != "":
+		rdmaDev := rdmatypes.RdmaDevState{SandboxRdmaDevName: conf.RdmaDevice, ContainerRdmaDevName: conf.RdmaDevice}
+		if err := plugin.restoreStaleRdmaDevs([]rdmatypes.RdmaDevState{rdmaDev}, nsPath); err != nil {
+			log.Error().Msgf("failed to restore RDMA device %s to default namespace. %v", conf.RdmaDevice, err)
+		}
+	case conf.DeviceID != "":
+		var rdmaDevs []string
+		if utils.IsPCIAddress(conf.DeviceID) {
+			rdmaDevs = plugin.rdmaManager.GetRdmaDevsForPciDev(conf.DeviceID)
+		} else {
+			rdmaDevs = plugin.rdmaManager.GetRdmaDevsForAuxDev(conf.DeviceID)
+		}
+		if len(rdmaDevs) == 0 {
+			log.Error().Msgf("no RDMA devices of device %s found in default namespace, they are restored "+
+				"once namespace %s is destroyed", conf.DeviceID, nsPath)
+		}
+	}
+}
+
+// Ensure RDMA device of a stale attachment is back in current (default) namespace
+func (plugin *rdmaCniPlugin) restoreStaleRdmaDev(rdmaDev rdmatypes.RdmaDevState, nsPath string) error {
+	currNs, err := plugin.nsManager.GetCurrentNS()
+	if err != nil {
+		return fmt.Errorf("failed to open current network namespace: %v", err)
+	}
+	defer currNs.Close()
+
+	inCurrent, err := plugin.isRdmaDevInNs(rdmaDev.ContainerRdmaDevName, currNs)
+	if err != nil {
+		return fmt.Errorf("failed to get RDMA devices in current network namespace. %v", err)
+	}
+	if inCurrent {
+		if err = plugin.verifyRdmaDev(rdmaDev.ContainerRdmaDevName, rdmaDev); err != nil {
+			log.Error().Msgf("not restoring RDMA device %s. %v", rdmaDev.ContainerRdmaDevName, err)
+			return nil
+		}
+		// Kernel keeps the container name of RDMA devices it returns to the default namespace
+		if rdmaDev.ContainerRdmaDevName != rdmaDev.SandboxRdmaDevName {
+			return plugin.rdmaManager.RenameRdmaDev(rdmaDev.ContainerRdmaDevName, rdmaDev.SandboxRdmaDevName)
+		}
+		return nil
+	}
+	if nsPath == "" {
+		log.Warn().Msgf("namespace of RDMA device %s is unknown and it is not in default namespace",
+			rdmaDev.ContainerRdmaDevName)
+		return nil
+	}
+
+	err = plugin.moveRdmaDevFromNs(rdmaDev, nsPath)
+	if errors.Is(err, errRdmaDevMismatch) {
+		log.Error().Msgf("not moving RDMA device %s from namespace %s. %v", rdmaDev.ContainerRdmaDevName, nsPath, err)
+		return nil
+	}
+	var nsNotExistErr ns.NSPathNotExistErr
+	if errors.As(err, &nsNotExistErr) {
+		// Namespace is gone and RDMA device is not in default namespace, so it no longer exists
+		log.Warn().Msgf("namespace %s no longer exists and RDMA device %s is not in default namespace",
+			nsPath, rdmaDev.ContainerRdmaDevName)
+		return nil
+	}
+	return err
+}
+
+func (plugin *rdmaCniPlugin) CmdGC(args *skel.CmdArgs) error {
+	log.Info().Msgf("RDMA-CNI: cmdGC")
+	conf, err := plugin.parseConf(args.StdinData, args.Args)
+	if err != nil {
+		return err
+	}
+	if conf.Args.CNI.Debug {
+		setDebugMode()
+	}
+	log.Debug().Msgf("CmdGC() args: %v ", args)
+	plugin.initStateCache(conf)
+
+	validAttachments := make(map[types.GCAttachment]bool, len(conf.ValidAttachments))
+	for _, attachment := range conf.ValidAttachments {
+		validAttachments[attachment] = true
+	}
+
+	refs, err := plugin.stateCache.ListByNetwork(conf.Name)
+	if err != nil {
+		return fmt.Errorf("failed to list cache entries of network %q. %v", conf.Name, err)
+	}
+
+	var errs []error
+	for _, ref := range refs {
+		if err = plugin.releaseStaleAttachment(ref, conf.Name, validAttachments); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err = plugin.releaseGoneUnknownAttachments(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Release cache entries created before the attachment was recorded in state, they cannot be matched to the network
+// or its valid attachments so they are only released once their attachment is provably gone
+func (plugin *rdmaCniPlugin) releaseGoneUnknownAttachments() error {
+	mode, err := plugin.rdmaManager.GetSystemRdmaMode()
+	if err != nil || mode != rdma.RdmaSysModeExclusive {
+		return nil
+	}
+	refs, err := plugin.stateCache.ListByNetwork("")
+	if err != nil {
+		return fmt.Errorf("failed to list cache entries of unknown network. %v", err)
+	}
+	var errs []error
+	for _, ref := range refs {
+		if err = plugin.releaseGoneUnknownAttachment(ref); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Release cache entry of unknown network if its attachment is gone
+func (plugin *rdmaCniPlugin) releaseGoneUnknownAttachment(ref cache.StateRef) error {
+	unlockRef, err := plugin.locker.LockRef(ref)
+	if err != nil {
+		return fmt.Errorf("failed to lock cache entry(%q). %v", ref, err)
+	}
+	defer unlockRef()
+
+	rdmaState := rdmatypes.RdmaNetState{}
+	if err = plugin.stateCache.Load(ref, &rdmaState); err != nil {
+		log.Warn().Msgf("failed to load cache entry(%q), skipping. %v", ref, err)
+		return nil
+	}
+	if rdmaState.Network != "" || !plugin.isAttachmentGone(&rdmaState) {
+		return nil
+	}
+
+	log.Info().Msgf("releasing gone attachment of unknown network, cache entry(%q)", ref)
+	if err = plugin.restoreStaleRdmaDevs(rdmaState.GetRdmaDevs(), rdmaState.Netns); err != nil {
+		return fmt.Errorf("failed to restore RDMA devices of stale cache entry(%q). %v", ref, err)
+	}
+	if err = plugin.stateCache.Delete(ref); err != nil {
+		return err
+	}
+	plugin.removeRefLock(ref)
+	return nil
+}
+
+// Release attachment of the given cache entry if it belongs to the network and is not valid
+func (plugin *rdmaCniPlugin) releaseStaleAttachment(
+	ref cache.StateRef, network string, validAttachments map[types.GCAttachment]bool) error {
+	unlockRef, err := plugin.locker.LockRef(ref)
+	if err != nil {
+		return fmt.Errorf("failed to lock cache entry(%q). %v", ref, err)
+	}
+	defer unlockRef()
+
+	rdmaState := rdmatypes.RdmaNetState{}
+	if err = plugin.stateCache.Load(ref, &rdmaState); err != nil {
+		log.Warn().Msgf("failed to load cache entry(%q), skipping. %v", ref, err)
+		return nil
+	}
+	// Entries created before the attachment was recorded in state are released by releaseGoneUnknownAttachments
+	if rdmaState.Network != network {
+		return nil
+	}
+	attachment := types.GCAttachment{ContainerID: rdmaState.ContainerID, IfName: rdmaState.IfName}
+	if validAttachments[attachment] {
+		return nil
+	}
+
+	log.Info().Msgf("releasing stale attachment %+v, cache entry(%q)", attachment, ref)
+	// RDMA devices shared with the container were never moved
+	if !rdmaState.IsShared() {
+		if err = plugin.restoreStaleRdmaDevs(rdmaState.GetRdmaDevs(), rdmaState.Netns); err != nil {
+			return fmt.Errorf("failed to restore RDMA devices of stale cache entry(%q). %v", ref, err)
+		}
+	}
+	if err = plugin.restoreVfGUIDLocked(&rdmaState); err != nil {
+		return fmt.Errorf("failed to restore GUID of stale cache entry(%q). %v", ref, err)
+	}
+	if err = plugin.stateCache.Delete(ref); err != nil {
+		return err
+	}
+	plugin.removeRefLock(ref)
+	return nil
+}
+
+// Ensure RDMA devices of a stale attachment, or of an attachment whose namespace is gone, are back in current (default)
+// namespace, under node lock
+func (plugin *rdmaCniPlugin) restoreStaleRdmaDevs(rdmaDevs []rdmatypes.RdmaDevState, nsPath string) error {
+	unlockNode, err := plugin.locker.LockNode()
+	if err != nil {
+		return fmt.Errorf("failed to lock node. %v", err)
+	}
+	defer unlockNode()
+
+	var errs []error
+	for _, rdmaDev := range rdmaDevs {
+		if err = plugin.restoreStaleRdmaDev(rdmaDev, nsPath); err != nil {
+			errs = append(errs, fmt.Errorf("RDMA device %s: %v", rdmaDev.ContainerRdmaDevName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (plugin *rdmaCniPlugin) CmdStatus(args *skel.CmdArgs) error {
+	log.Info().Msgf("RDMA-CNI: cmdStatus")
+	conf, err := plugin.parseConf(args.StdinData, args.Args)
+	if err != nil {
+		return err
+	}
+	if conf.Args.CNI.Debug {
+		setDebugMode()
+	}
+	log.Debug().Msgf("CmdStatus() args: %v ", args)
+	plugin.initStateCache(conf)
+
+	if _, err = plugin.rdmaManager.GetRdmaDevs(); err != nil {
+		return types.NewError(errPluginNotAvailable, "RDMA netlink family is not reachable", err.Error())
+	}
+	if conf.Mode != rdmatypes.RdmaNetModeShared {
+		// Switching the mode is opt-in and only possible here, before any network namespace other than
+		// the initial one is created
+		if conf.AutoExclusiveMode {
+			log.Info().Msgf("autoExclusiveMode is set, switching RDMA subsystem mode to %s if needed",
+				rdma.RdmaSysModeExclusive)
+		}
+		if err = plugin.ensureRdmaSystemMode(conf.AutoExclusiveMode); err != nil {
+			return types.NewError(errPluginNotAvailable, "RDMA subsystem is not ready", err.Error())
+		}
+	}
+	if err = plugin.stateCache.EnsureWritable(); err != nil {
+		return types.NewError(errPluginNotAvailable, "RDMA state cache is not writable", err.Error())
+	}
+	if err = plugin.ensureStateCachePersistent(conf); err != nil {
+		return types.NewError(errPluginNotAvailable, "RDMA state cache is not persistent", err.Error())
+	}
+	return nil
+}
+
+// Wait up to rdmaDevWaitTimeout for RDMA devices of the network configuration to be registered, as they may be
+// registered shortly after their device is created. Done before locking the node not to delay concurrent CNI
+// invocations, RDMA devices are looked up again by getRdmaDevices once it is locked
+func (plugin *rdmaCniPlugin) waitRdmaDevices(conf *rdmatypes.RdmaNetConf) {
+	timeout := conf.GetRdmaDevWaitTimeout()
+	switch {
+	case conf.RdmaDevice != "":
+		_ = plugin.rdmaManager.WaitRdmaDev(conf.RdmaDevice, timeout)
+	case utils.IsPCIAddress(conf.DeviceID):
+		plugin.rdmaManager.WaitRdmaDevsForPciDev(conf.DeviceID, timeout)
+	default:
+		plugin.rdmaManager.WaitRdmaDevsForAuxDev(conf.DeviceID, timeout)
+	}
+}
+
+// Get RDMA devices to move to container, either the explicitly provided RDMA device or
+// the RDMA devices associated with DeviceID which match the network configuration.
+// RDMA devices are expected to be registered already, see waitRdmaDevices
+func (plugin *rdmaCniPlugin) getRdmaDevices(conf *rdmatypes.RdmaNetConf) ([]string, error) {
+	// RDMA device explicitly provided, no need to look it up by DeviceID
+	if conf.RdmaDevice != "" {
+		if err := plugin.rdmaManager.WaitRdmaDev(conf.RdmaDevice, 0); err != nil {
+			return nil, err
+		}
+		return []string{conf.RdmaDevice}, nil
+	}
+
+	var rdmaDevs []string
+	if utils.IsPCIAddress(conf.DeviceID) {
+		rdmaDevs = plugin.rdmaManager.WaitRdmaDevsForPciDev(conf.DeviceID, 0)
+	} else {
+		rdmaDevs = plugin.rdmaManager.WaitRdmaDevsForAuxDev(conf.DeviceID, 0)
+	}
+	if len(rdmaDevs) == 0 {
+		return nil, fmt.Errorf("no RDMA devices found after waiting %v", conf.GetRdmaDevWaitTimeout())
+	}
+
+	rdmaDevs, err := plugin.filterRdmaDevs(rdmaDevs, conf)
+	if err != nil {
+		return nil, err
+	}
+	if len(rdmaDevs) == 0 {
+		return nil, errors.New("no RDMA devices match rdmaDevPattern and rdmaDevPort")
+	}
+
+	if !conf.AllRdmaDevs && len(rdmaDevs) != 1 {
+		// Expecting exactly one RDMA device
+		return nil, fmt.Errorf(
+			"discovered more than one RDMA device %v. Set allRdmaDevs to move all of them "+
+				"or select one with rdmaDevPattern or rdmaDevPort", rdmaDevs)
+	}
+	return rdmaDevs, nil
+}
+
+// Filter RDMA devices according to RDMA device pattern and port in network configuration
+func (plugin *rdmaCniPlugin) filterRdmaDevs(rdmaDevs []string, conf *rdmatypes.RdmaNetConf) ([]string, error) {
+	pattern, err := regexp.Compile(conf.RdmaDevPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rdmaDevPattern %q. %v", conf.RdmaDevPattern, err)
+	}
+
+	filtered := make([]string, 0, len(rdmaDevs))
+	for _, rdmaDev := range rdmaDevs {
+		if !pattern.MatchString(rdmaDev) {
+			continue
+		}
+		if conf.RdmaDevPort != 0 &&
+			!slices.Contains(plugin.rdmaManager.GetRdmaDevPorts(rdmaDev), strconv.Itoa(conf.RdmaDevPort)) {
+			continue
+		}
+		filtered = append(filtered, rdmaDev)
+	}
+	return filtered, nil
 }
 
 func setupLogging() {
@@ -351,15 +1680,18 @@ func main() {
 
 	setupLogging()
 	plugin := rdmaCniPlugin{
-		rdmaManager: rdma.NewRdmaManager(),
-		nsManager:   newNsManager(),
-		stateCache:  cache.NewStateCache(),
+		rdmaManager:   rdma.NewRdmaManager(),
+		nsManager:     newNsManager(),
+		newStateCache: cache.NewStateCache,
+		locker:        cache.NewLocker(),
 	}
 	skel.PluginMainFuncs(
 		skel.CNIFuncs{
-			Add:   plugin.CmdAdd,
-			Check: plugin.CmdCheck,
-			Del:   plugin.CmdDel,
+			Add:    plugin.CmdAdd,
+			Check:  plugin.CmdCheck,
+			Del:    plugin.CmdDel,
+			GC:     plugin.CmdGC,
+			Status: plugin.CmdStatus,
 		},
 		cniversion.All, "")
 }
@@ -18,11 +18,20 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
+	cniversion "github.com/containernetworking/cni/pkg/version"
 	"github.com/containernetworking/plugins/pkg/ns"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -33,6 +42,7 @@ import (
 	"github.com/k8snetworkplumbingwg/rdma-cni/pkg/rdma"
 	rdmaMocks "github.com/k8snetworkplumbingwg/rdma-cni/pkg/rdma/mocks"
 	rdmaTypes "github.com/k8snetworkplumbingwg/rdma-cni/pkg/types"
+	"github.com/k8snetworkplumbingwg/rdma-cni/pkg/utils"
 )
 
 func generateNetConfCmdDel(netName string) rdmaTypes.RdmaNetConf {
@@ -97,11 +107,25 @@ func generateArgs(nsPath, cid, cIfname string, netconf *rdmaTypes.RdmaNetConf) s
 func generateRdmaNetState(deviceID, sanboxRdmaDev, containerRdmaDev string) rdmaTypes.RdmaNetState {
 	state := rdmaTypes.NewRdmaNetState()
 	state.DeviceID = deviceID
-	state.SandboxRdmaDevName = sanboxRdmaDev
-	state.ContainerRdmaDevName = containerRdmaDev
+	state.SetRdmaDevs([]rdmaTypes.RdmaDevState{
+		{SandboxRdmaDevName: sanboxRdmaDev, ContainerRdmaDevName: containerRdmaDev}})
 	return state
 }
 
+func setRdmaNetStateAttachment(state *rdmaTypes.RdmaNetState, netName, cid, cIfname, nsPath string) {
+	state.Network = netName
+	state.ContainerID = cid
+	state.IfName = cIfname
+	state.Netns = nsPath
+}
+
+func generateNetConfCmdGC(netName string, validAttachments []types.GCAttachment) rdmaTypes.RdmaNetConf {
+	netconf := generateNetConfCmdDel(netName)
+	netconf.CNIVersion = "1.1.0"
+	netconf.ValidAttachments = validAttachments
+	return netconf
+}
+
 type dummyNetNs struct {
 	fd   uintptr
 	path string
@@ -128,9 +152,14 @@ func (dns *dummyNetNs) Close() error {
 }
 
 type dummyNsMananger struct {
+	nonInitNS []string
+	goneNS    []string
 }
 
 func (nsm *dummyNsMananger) GetNS(nspath string) (ns.NetNS, error) {
+	if slices.Contains(nsm.goneNS, nspath) {
+		return nil, ns.NSPathNotExistErr{}
+	}
 	return &dummyNetNs{path: nspath, fd: 17}, nil
 }
 
@@ -138,24 +167,61 @@ func (nsm *dummyNsMananger) GetCurrentNS() (ns.NetNS, error) {
 	return &dummyNetNs{path: "/proc/2/ns/net", fd: 17}, nil
 }
 
+func (nsm *dummyNsMananger) ListNonInitNS() ([]string, error) {
+	return nsm.nonInitNS, nil
+}
+
+// Matches any time to wait for RDMA devices, they are waited for before the node is locked and checked once locked
+var anyTimeout = mock.AnythingOfType("time.Duration")
+
+type dummyLocker struct {
+	lockedRefs  []cache.StateRef
+	nodeLocks   int
+	removedRefs []cache.StateRef
+}
+
+func (dl *dummyLocker) LockRef(ref cache.StateRef) (cache.Unlock, error) {
+	dl.lockedRefs = append(dl.lockedRefs, ref)
+	return func() {}, nil
+}
+
+func (dl *dummyLocker) LockNode() (cache.Unlock, error) {
+	dl.nodeLocks++
+	return func() {}, nil
+}
+
+func (dl *dummyLocker) RemoveRefLock(ref cache.StateRef) error {
+	dl.removedRefs = append(dl.removedRefs, ref)
+	return nil
+}
+
 var _ = Describe("Main", func() {
 	var (
 		plugin         rdmaCniPlugin
 		dummyNsMgr     dummyNsMananger
+		locker         dummyLocker
 		rdmaMgrMock    rdmaMocks.MockManager
 		stateCacheMock cacheMocks.MockStateCache
+		stateDirs      []string
 		t              GinkgoTInterface
 	)
 
 	JustBeforeEach(func() {
 		rdmaMgrMock = rdmaMocks.MockManager{}
 		dummyNsMgr = dummyNsMananger{}
+		locker = dummyLocker{}
 		stateCacheMock = cacheMocks.MockStateCache{}
+		stateDirs = nil
 		t = GinkgoT()
 		plugin = rdmaCniPlugin{
 			rdmaManager: &rdmaMgrMock,
-			stateCache:  &stateCacheMock,
-			nsManager:   &dummyNsMgr,
+			newStateCache: func(stateDir string) cache.StateCache {
+				stateDirs = append(stateDirs, stateDir)
+				return &stateCacheMock
+			},
+			stateCache: &stateCacheMock,
+			nsManager:  &dummyNsMgr,
+			locker:     &locker,
 		}
 	})
 
@@ -164,14 +230,14 @@ var _ = Describe("Main", func() {
 		Context("Bad flows", func() {
 			It("Should error out if rdma system namespace mode is not exclusive", func() {
 				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeShared, nil)
-				err := plugin.ensureRdmaSystemMode()
+				err := plugin.ensureRdmaSystemMode(false)
 				Expect(err).To(HaveOccurred())
 				rdmaMgrMock.AssertExpectations(t)
 			})
 			It("Should error out on failure to get rdma system namespace mode", func() {
 				retErr := fmt.Errorf("error")
 				rdmaMgrMock.On("GetSystemRdmaMode").Return("", retErr)
-				err := plugin.ensureRdmaSystemMode()
+				err := plugin.ensureRdmaSystemMode(false)
 				Expect(err).To(HaveOccurred())
 				rdmaMgrMock.AssertExpectations(t)
 			})
@@ -179,9 +245,32 @@ var _ = Describe("Main", func() {
 		Context("Good flow", func() {
 			It("Should succeed if rdma system namespace mode is exclusive", func() {
 				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
-				Expect(plugin.ensureRdmaSystemMode()).To(Succeed())
+				Expect(plugin.ensureRdmaSystemMode(false)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+		})
+		Context("Switch to exclusive mode requested", func() {
+			It("Should switch rdma system namespace mode to exclusive", func() {
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeShared, nil)
+				rdmaMgrMock.On("SetSystemRdmaMode", rdma.RdmaSysModeExclusive).Return(nil)
+				Expect(plugin.ensureRdmaSystemMode(true)).To(Succeed())
 				rdmaMgrMock.AssertExpectations(t)
 			})
+			It("Should not switch if network namespaces other than the initial one exist", func() {
+				dummyNsMgr.nonInitNS = []string{"/var/run/netns/cni-1234"}
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeShared, nil)
+				err := plugin.ensureRdmaSystemMode(true)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("/var/run/netns/cni-1234"))
+				rdmaMgrMock.AssertNotCalled(t, "SetSystemRdmaMode", mock.Anything)
+			})
+			It("Should fail if kernel refuses to switch", func() {
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeShared, nil)
+				rdmaMgrMock.On("SetSystemRdmaMode", rdma.RdmaSysModeExclusive).Return(syscall.EBUSY)
+				err := plugin.ensureRdmaSystemMode(true)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("network namespaces other than the initial one"))
+			})
 		})
 	})
 
@@ -219,24 +308,127 @@ var _ = Describe("Main", func() {
 				nsPath := "/proc/666/ns/net"
 				currNs, _ := dummyNsMgr.GetCurrentNS()
 				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, currNs).Return(nil)
-				Expect(plugin.moveRdmaDevFromNs(rdmaDev, nsPath)).To(Succeed())
+				cached := rdmaTypes.RdmaDevState{SandboxRdmaDevName: rdmaDev, ContainerRdmaDevName: rdmaDev}
+				Expect(plugin.moveRdmaDevFromNs(cached, nsPath)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				rdmaMgrMock.AssertNotCalled(t, "GetRdmaDevInfo", mock.Anything)
+			})
+		})
+		Context("Good flow with renamed RDMA device", func() {
+			It("Should restore RDMA device name and move it to current namespace", func() {
+				nsPath := "/proc/666/ns/net"
+				currNs, _ := dummyNsMgr.GetCurrentNS()
+				rdmaMgrMock.On("RenameRdmaDev", "rdma0", "mlx5_5").Return(nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_5", currNs).Return(nil)
+				cached := rdmaTypes.RdmaDevState{SandboxRdmaDevName: "mlx5_5", ContainerRdmaDevName: "rdma0"}
+				Expect(plugin.moveRdmaDevFromNs(cached, nsPath)).To(Succeed())
 				rdmaMgrMock.AssertExpectations(t)
 			})
 		})
+		Context("Node GUID recorded in cached state", func() {
+			cached := rdmaTypes.RdmaDevState{
+				SandboxRdmaDevName: "mlx5_5", ContainerRdmaDevName: "mlx5_5", NodeGUID: "98:03:9b:03:00:9e:e3:5e"}
+
+			It("Should move RDMA device with the same node GUID", func() {
+				currNs, _ := dummyNsMgr.GetCurrentNS()
+				rdmaMgrMock.On("GetRdmaDevInfo", "mlx5_5").Return(
+					&rdmaTypes.RdmaDevInfo{Name: "mlx5_5", NodeGUID: "98:03:9b:03:00:9e:e3:5e"}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_5", currNs).Return(nil)
+				Expect(plugin.moveRdmaDevFromNs(cached, "/proc/666/ns/net")).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+			It("Should fail without moving RDMA device with another node GUID", func() {
+				rdmaMgrMock.On("GetRdmaDevInfo", "mlx5_5").Return(
+					&rdmaTypes.RdmaDevInfo{Name: "mlx5_5", NodeGUID: "98:03:9b:03:00:9e:e3:5f"}, nil)
+				err := plugin.moveRdmaDevFromNs(cached, "/proc/666/ns/net")
+				Expect(errors.Is(err, errRdmaDevMismatch)).To(BeTrue())
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+		})
 		Context("Bad flow", func() {
 			It("Should fail", func() {
 				retErr := fmt.Errorf("error occurred")
 				rdmaMgrMock.On("MoveRdmaDevToNs",
 					mock.AnythingOfType("string"),
 					mock.AnythingOfType("*main.dummyNetNs")).Return(retErr)
-				err := plugin.moveRdmaDevFromNs("mlx5_5", "/proc/666/ns/net")
+				cached := rdmaTypes.RdmaDevState{SandboxRdmaDevName: "mlx5_5", ContainerRdmaDevName: "mlx5_5"}
+				err := plugin.moveRdmaDevFromNs(cached, "/proc/666/ns/net")
+				Expect(err).To(HaveOccurred())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+		})
+	})
+
+	Describe("Test renameRdmaDev()", func() {
+		Context("Name without templates", func() {
+			It("Should rename RDMA device to the given name", func() {
+				rdmaMgrMock.On("RenameRdmaDev", "mlx5_5", "rdma_net1").Return(nil)
+				name, err := plugin.renameRdmaDev("mlx5_5", "rdma_{ifname}", "net1")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(name).To(Equal("rdma_net1"))
+				rdmaMgrMock.AssertExpectations(t)
+			})
+		})
+		Context("Name with index template", func() {
+			It("Should rename RDMA device to the first free name", func() {
+				rdmaMgrMock.On("RenameRdmaDev", "mlx5_5", "rdma0").Return(
+					fmt.Errorf("failed to rename. %w", syscall.EEXIST))
+				rdmaMgrMock.On("RenameRdmaDev", "mlx5_5", "rdma1").Return(nil)
+				name, err := plugin.renameRdmaDev("mlx5_5", "rdma{index}", "net1")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(name).To(Equal("rdma1"))
+				rdmaMgrMock.AssertExpectations(t)
+			})
+			It("Should fail on errors other than name already in use", func() {
+				rdmaMgrMock.On("RenameRdmaDev", "mlx5_5", "rdma0").Return(fmt.Errorf("error"))
+				_, err := plugin.renameRdmaDev("mlx5_5", "rdma{index}", "net1")
 				Expect(err).To(HaveOccurred())
 				rdmaMgrMock.AssertExpectations(t)
 			})
 		})
+		Context("Name too long", func() {
+			It("Should fail", func() {
+				_, err := plugin.renameRdmaDev("mlx5_5", strings.Repeat("r", 64), "net1")
+				Expect(err).To(HaveOccurred())
+				rdmaMgrMock.AssertNotCalled(t, "RenameRdmaDev", mock.Anything, mock.Anything)
+			})
+		})
 	})
 
 	Describe("Test CmdAdd()", func() {
+		var (
+			// Cache entries of attachments, by device ID and RDMA device
+			attachments map[string][]cache.StateRef
+			// Cached states, by cache entry
+			cachedStates map[cache.StateRef]rdmaTypes.RdmaNetState
+			// Node GUIDs of RDMA devices, by RDMA device
+			nodeGUIDs map[string]string
+		)
+
+		JustBeforeEach(func() {
+			attachments = map[string][]cache.StateRef{}
+			cachedStates = map[cache.StateRef]rdmaTypes.RdmaNetState{}
+			nodeGUIDs = map[string]string{}
+			rdmaMgrMock.On("GetRdmaDevInfo", mock.Anything).Return(
+				func(rdmaDev string) (*rdmaTypes.RdmaDevInfo, error) {
+					return &rdmaTypes.RdmaDevInfo{Name: rdmaDev, NodeGUID: nodeGUIDs[rdmaDev]}, nil
+				}).Maybe()
+			listAttachments := func(device string) ([]cache.StateRef, error) {
+				return attachments[device], nil
+			}
+			stateCacheMock.On("ListByDeviceID", mock.Anything).Return(listAttachments).Maybe()
+			stateCacheMock.On("ListByRdmaDev", mock.Anything).Return(listAttachments).Maybe()
+			stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"), mock.AnythingOfType("*types.RdmaNetState")).
+				Return(func(ref cache.StateRef, state interface{}) error {
+					cached, ok := cachedStates[ref]
+					if !ok {
+						return os.ErrNotExist
+					}
+					*state.(*rdmaTypes.RdmaNetState) = cached
+					return nil
+				}).Maybe()
+		})
+
 		Context("Valid configuration provided", func() {
 			It("Should succeed and move Rdma device associated with provided PCI DeviceID to Namespace", func() {
 				pciDev := "0000:04:00.5"
@@ -249,15 +441,18 @@ var _ = Describe("Main", func() {
 				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
 				args := generateArgs(cnsPath, cid, cIfname, &netconf)
 				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
-				rdmaMgrMock.On("GetRdmaDevsForPciDev", pciDev).Return([]string{rdmaDev}, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{rdmaDev}, nil)
 				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
 				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
 				expectedState := generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
+				setRdmaNetStateAttachment(&expectedState, netName, cid, cIfname, cnsPath)
 				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), &expectedState).Return(nil)
 				err := plugin.CmdAdd(&args)
 				Expect(err).ToNot(HaveOccurred())
 				rdmaMgrMock.AssertExpectations(t)
 				stateCacheMock.AssertExpectations(t)
+				Expect(locker.lockedRefs).To(Equal([]cache.StateRef{"some-ref"}))
+				Expect(locker.nodeLocks).To(Equal(1))
 			})
 			It("Should succeed and move Rdma device associated with auxiliary device DeviceID to Namespace", func() {
 				auxDev := "mlx5_core.sf.6"
@@ -270,10 +465,11 @@ var _ = Describe("Main", func() {
 				netconf := generateNetConfCmdAdd(netName, cIfname, auxDev)
 				args := generateArgs(cnsPath, cid, cIfname, &netconf)
 				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
-				rdmaMgrMock.On("GetRdmaDevsForAuxDev", auxDev).Return([]string{rdmaDev}, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForAuxDev", auxDev, anyTimeout).Return([]string{rdmaDev}, nil)
 				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
 				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
 				expectedState := generateRdmaNetState(auxDev, rdmaDev, rdmaDev)
+				setRdmaNetStateAttachment(&expectedState, netName, cid, cIfname, cnsPath)
 				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), &expectedState).Return(nil)
 				err := plugin.CmdAdd(&args)
 				Expect(err).ToNot(HaveOccurred())
@@ -281,66 +477,1415 @@ var _ = Describe("Main", func() {
 				stateCacheMock.AssertExpectations(t)
 			})
 		})
-		// TODO(adrian): Add additional tests to cover bad flows / differen network configurations
-	})
-
-	Describe("Test CmdDel()", func() {
-		Context("Valid configuration provided", func() {
-			It("Should succeed and move Rdma device associated with PCI net device back to sandbox namespace", func() {
+		Context("RDMA subsystem mode is shared and autoExclusiveMode is set", func() {
+			It("Should fail without attempting to switch RDMA subsystem mode", func() {
+				netconf := generateNetConfCmdAdd("rdma-net", "net1", "0000:04:00.5")
+				netconf.AutoExclusiveMode = true
+				args := generateArgs("/proc/12444/ns/net", "a1b2c3d4e5f6", "net1", &netconf)
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeShared, nil)
+				err := plugin.CmdAdd(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("only switch it from STATUS"))
+				rdmaMgrMock.AssertNotCalled(t, "SetSystemRdmaMode", mock.Anything)
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+		})
+		Context("RDMA device has node GUID", func() {
+			It("Should record node GUID of RDMA device in cache", func() {
 				pciDev := "0000:04:00.5"
 				netName := "rdma-net"
 				rdmaDev := "mlx5_4"
 				cIfname := "net1"
 				cid := "a1b2c3d4e5f6"
 				cnsPath := "/proc/12444/ns/net"
-				cns, _ := dummyNsMgr.GetCurrentNS()
-				rdmaState := generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
-				netconf := generateNetConfCmdDel(netName)
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				nodeGUIDs[rdmaDev] = "98:03:9b:03:00:9e:e3:5e"
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
 				args := generateArgs(cnsPath, cid, cIfname, &netconf)
-				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
-				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
-					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
-					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
-					*arg = rdmaState
-				})
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return(
+					[]string{rdmaDev})
 				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
-				stateCacheMock.On("Delete", mock.AnythingOfType("cache.StateRef")).Return(nil)
-				err := plugin.CmdDel(&args)
-				Expect(err).ToNot(HaveOccurred())
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				expectedState := generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
+				expectedState.RdmaDevs[0].NodeGUID = "98:03:9b:03:00:9e:e3:5e"
+				setRdmaNetStateAttachment(&expectedState, netName, cid, cIfname, cnsPath)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), &expectedState).Return(nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
 				rdmaMgrMock.AssertExpectations(t)
 				stateCacheMock.AssertExpectations(t)
 			})
-			It("Should succeed and move Rdma device associated with auxiliary device back to sandbox namespace", func() {
-				auxDev := "mlx5_core.sf.6"
+		})
+		Context("Container RDMA device name provided", func() {
+			It("Should move Rdma device to Namespace and rename it", func() {
+				pciDev := "0000:04:00.5"
 				netName := "rdma-net"
-				rdmaDev := "mlx5_6"
-				cIfname := "net2"
+				rdmaDev := "mlx5_4"
+				cIfname := "net1"
 				cid := "a1b2c3d4e5f6"
 				cnsPath := "/proc/12444/ns/net"
-				cns, _ := dummyNsMgr.GetCurrentNS()
-				rdmaState := generateRdmaNetState(auxDev, rdmaDev, rdmaDev)
-				netconf := generateNetConfCmdDel(netName)
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.ContainerRdmaDevName = "rdma{index}"
 				args := generateArgs(cnsPath, cid, cIfname, &netconf)
-				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
-				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
-					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
-					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
-					*arg = rdmaState
-				})
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{rdmaDev}, nil)
 				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
-				stateCacheMock.On("Delete", mock.AnythingOfType("cache.StateRef")).Return(nil)
-				err := plugin.CmdDel(&args)
+				rdmaMgrMock.On("RenameRdmaDev", rdmaDev, "rdma0").Return(nil)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				expectedState := generateRdmaNetState(pciDev, rdmaDev, "rdma0")
+				setRdmaNetStateAttachment(&expectedState, netName, cid, cIfname, cnsPath)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), &expectedState).Return(nil)
+				err := plugin.CmdAdd(&args)
 				Expect(err).ToNot(HaveOccurred())
 				rdmaMgrMock.AssertExpectations(t)
 				stateCacheMock.AssertExpectations(t)
 			})
+			It("Should fail on invalid container RDMA device name", func() {
+				netconf := generateNetConfCmdAdd("rdma-net", "net1", "0000:04:00.5")
+				netconf.ContainerRdmaDevName = "rdma/{index}"
+				args := generateArgs("/proc/12444/ns/net", "a1b2c3d4e5f6", "net1", &netconf)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+			})
 		})
-		// TODO(adrian): Add additional tests to cover bad flows / different network configurations
-	})
+		Context("RDMA device wait timeout provided", func() {
+			pciDev := "0000:04:00.5"
+			netName := "rdma-net"
+			cIfname := "net1"
+			cid := "a1b2c3d4e5f6"
+			cnsPath := "/proc/12444/ns/net"
 
-	Describe("Test CmdCheck()", func() {
-		It("Should basically do nothing", func() {
-			Expect(plugin.CmdCheck(nil)).To(Succeed())
+			It("Should wait for RDMA devices up to the provided timeout", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.RdmaDevWaitTimeout = &rdmaTypes.Duration{Duration: 10 * time.Second}
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, 10*time.Second).Return([]string{"mlx5_4"})
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, time.Duration(0)).Return([]string{"mlx5_4"})
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_4", cns).Return(nil)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				Expect(locker.nodeLocks).To(Equal(1))
+			})
+			It("Should fail if no RDMA devices are registered before timeout", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.RdmaDevWaitTimeout = &rdmaTypes.Duration{}
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, time.Duration(0)).Return([]string{})
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+				stateCacheMock.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+			})
+			It("Should fail on negative timeout", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.RdmaDevWaitTimeout = &rdmaTypes.Duration{Duration: -time.Second}
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+			})
+			It("Should fail on timeout which is not well below the lock timeout", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.RdmaDevWaitTimeout = &rdmaTypes.Duration{Duration: cache.DefaultLockTimeout}
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+				rdmaMgrMock.AssertNotCalled(t, "WaitRdmaDevsForPciDev", mock.Anything, mock.Anything)
+			})
+			It("Should fail on timeout which is not a duration string", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				args.StdinData = []byte(`{"cniVersion": "1.0.0", "name": "rdma-net", "type": "rdma", ` +
+					`"rdmaDevWaitTimeout": 5}`)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+			})
+		})
+		Context("GUID provided", func() {
+			pciDev := "0000:04:00.5"
+			netName := "rdma-net"
+			rdmaDev := "mlx5_4"
+			cIfname := "net1"
+			cid := "a1b2c3d4e5f6"
+			cnsPath := "/proc/12444/ns/net"
+			guid, _ := net.ParseMAC("00:11:22:33:44:55:66:77")
+			prevGUID, _ := net.ParseMAC("98:03:9b:03:00:9e:e3:5e")
+
+			JustBeforeEach(func() {
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return(
+					[]string{rdmaDev})
+				nodeGUIDs[rdmaDev] = prevGUID.String()
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+			})
+
+			It("Should set GUID of VF before moving RDMA device and record previous GUID", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.GUID = "00:11:22:33:44:55:66:77"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("SetVfGUID", pciDev, guid).Return(nil).Once()
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				expectedState := generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
+				setRdmaNetStateAttachment(&expectedState, netName, cid, cIfname, cnsPath)
+				expectedState.RdmaDevs[0].NodeGUID = "98:03:9b:03:00:9e:e3:5e"
+				expectedState.GUID = "00:11:22:33:44:55:66:77"
+				expectedState.PrevGUID = "98:03:9b:03:00:9e:e3:5e"
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), &expectedState).Return(nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should keep previous GUID recorded by a previous ADD of the attachment", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.GUID = "00:11:22:33:44:55:66:77"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				cached := generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
+				setRdmaNetStateAttachment(&cached, netName, cid, cIfname, "/proc/12333/ns/net")
+				cached.GUID = "00:11:22:33:44:55:66:77"
+				cached.PrevGUID = "98:03:9b:03:00:9e:e3:5e"
+				cachedStates["some-ref"] = cached
+				nodeGUIDs[rdmaDev] = guid.String()
+				rdmaMgrMock.On("SetVfGUID", pciDev, guid).Return(nil).Once()
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Save", cache.StateRef("some-ref"), mock.MatchedBy(func(state *rdmaTypes.RdmaNetState) bool {
+					return state.PrevGUID == "98:03:9b:03:00:9e:e3:5e"
+				})).Return(nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should prefer GUID provided in runtimeConfig over network configuration", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.GUID = "00:00:00:00:00:00:00:01"
+				netconf.RuntimeConfig.GUID = "00:11:22:33:44:55:66:77"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("SetVfGUID", pciDev, guid).Return(nil).Once()
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), mock.Anything).Return(nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+			It("Should restore previous GUID of VF if moving RDMA device fails", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.GUID = "00:11:22:33:44:55:66:77"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("SetVfGUID", pciDev, guid).Return(nil).Once()
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(fmt.Errorf("error"))
+				rdmaMgrMock.On("SetVfGUID", pciDev, prevGUID).Return(nil).Once()
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+			})
+			It("Should fail without moving RDMA device if GUID cannot be set", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.GUID = "00:11:22:33:44:55:66:77"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("SetVfGUID", pciDev, guid).Return(fmt.Errorf("error"))
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+			It("Should fail if DeviceID is not a PCI address", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, "mlx5_core.sf.4")
+				netconf.GUID = "00:11:22:33:44:55:66:77"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("WaitRdmaDevsForAuxDev", "mlx5_core.sf.4", anyTimeout).Return(
+					[]string{rdmaDev})
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+				rdmaMgrMock.AssertNotCalled(t, "SetVfGUID", mock.Anything, mock.Anything)
+			})
+			It("Should fail on invalid GUID", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.GUID = "00:11:22:33:44:55"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+			})
+			It("Should fail on GUID in shared mode", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.GUID = "00:11:22:33:44:55:66:77"
+				netconf.Mode = rdmaTypes.RdmaNetModeShared
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+			})
+		})
+		Context("Pkey provided", func() {
+			pciDev := "0000:04:00.5"
+			netName := "rdma-net"
+			rdmaDev := "mlx5_4"
+			cIfname := "net1"
+			cid := "a1b2c3d4e5f6"
+			cnsPath := "/proc/12444/ns/net"
+
+			JustBeforeEach(func() {
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return(
+					[]string{rdmaDev})
+				rdmaMgrMock.On("GetRdmaDevPorts", rdmaDev).Return([]string{"1"})
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+			})
+
+			It("Should move RDMA device whose pkey table contains pkey", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Pkey = "0x8001"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("GetRdmaDevPkeys", rdmaDev, "1").Return([]uint16{0xffff, 0x8001}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), mock.Anything).Return(nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+			It("Should fail without moving RDMA device whose pkey table does not contain pkey", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Pkey = "0x8001"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("GetRdmaDevPkeys", rdmaDev, "1").Return([]uint16{0xffff, 0x0001}, nil)
+				err := plugin.CmdAdd(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("pkey 0x8001"))
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+				stateCacheMock.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+			})
+			It("Should check only rdmaDevPort if set", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Pkey = "0x0001"
+				netconf.RdmaDevPort = 1
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("GetRdmaDevPkeys", rdmaDev, "1").Return([]uint16{0x8001}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), mock.Anything).Return(nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertNumberOfCalls(t, "GetRdmaDevPkeys", 1)
+			})
+			It("Should succeed if container IPoIB interface is on pkey", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Pkey = "0x8001"
+				netconf.CheckIPoIBPkey = true
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("GetIPoIBPkey", cIfname).Return(uint16(0x8001), nil)
+				rdmaMgrMock.On("GetRdmaDevPkeys", rdmaDev, "1").Return([]uint16{0xffff, 0x8001}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), mock.Anything).Return(nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+			It("Should fail if container IPoIB interface is on another pkey", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Pkey = "0x8001"
+				netconf.CheckIPoIBPkey = true
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("GetIPoIBPkey", cIfname).Return(uint16(0xffff), nil)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+			It("Should fail if container interface is not an IPoIB interface", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Pkey = "0x8001"
+				netconf.CheckIPoIBPkey = true
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("GetIPoIBPkey", cIfname).Return(uint16(0), fmt.Errorf("not ipoib"))
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+			It("Should fail on invalid pkey", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Pkey = "0x8000"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+			})
+			It("Should fail if checkIPoIBPkey is set without pkey", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.CheckIPoIBPkey = true
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+			})
+		})
+		Context("Multiple RDMA devices associated with DeviceID", func() {
+			pciDev := "0000:04:00.5"
+			netName := "rdma-net"
+			cIfname := "net1"
+			cid := "a1b2c3d4e5f6"
+			cnsPath := "/proc/12444/ns/net"
+
+			JustBeforeEach(func() {
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return(
+					[]string{"mlx5_4", "mlx5_5"}, nil)
+			})
+
+			It("Should fail if neither allRdmaDevs nor a device selector is set", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				err := plugin.CmdAdd(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("allRdmaDevs"))
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+			It("Should move all RDMA devices to Namespace if allRdmaDevs is set", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.AllRdmaDevs = true
+				netconf.ContainerRdmaDevName = "rdma{index}"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_4", cns).Return(nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_5", cns).Return(nil)
+				rdmaMgrMock.On("RenameRdmaDev", "mlx5_4", "rdma0").Return(nil)
+				rdmaMgrMock.On("RenameRdmaDev", "mlx5_5", "rdma0").Return(syscall.EEXIST)
+				rdmaMgrMock.On("RenameRdmaDev", "mlx5_5", "rdma1").Return(nil)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				expectedState := generateRdmaNetState(pciDev, "mlx5_4", "rdma0")
+				expectedState.SetRdmaDevs([]rdmaTypes.RdmaDevState{
+					{SandboxRdmaDevName: "mlx5_4", ContainerRdmaDevName: "rdma0"},
+					{SandboxRdmaDevName: "mlx5_5", ContainerRdmaDevName: "rdma1"},
+				})
+				setRdmaNetStateAttachment(&expectedState, netName, cid, cIfname, cnsPath)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), &expectedState).Return(nil)
+				err := plugin.CmdAdd(&args)
+				Expect(err).ToNot(HaveOccurred())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should restore moved RDMA devices if moving one of them fails", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.AllRdmaDevs = true
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_4", cns).Return(nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_5", cns).Return(fmt.Errorf("error"))
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_4", mock.AnythingOfType("*main.dummyNetNs")).Return(nil)
+				err := plugin.CmdAdd(&args)
+				Expect(err).To(HaveOccurred())
+				rdmaMgrMock.AssertNumberOfCalls(t, "MoveRdmaDevToNs", 3)
+				stateCacheMock.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+			})
+			It("Should undo renames and moves in reverse order and report undo errors if saving state fails", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				currNs, _ := dummyNsMgr.GetCurrentNS()
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.AllRdmaDevs = true
+				netconf.ContainerRdmaDevName = "rdma{index}"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				var calls []string
+				recordCall := func(args mock.Arguments) {
+					calls = append(calls, fmt.Sprintf("%v", args[0]))
+				}
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				rdmaMgrMock.On("MoveRdmaDevToNs", mock.Anything, cns).Return(nil)
+				rdmaMgrMock.On("RenameRdmaDev", "mlx5_4", "rdma0").Return(nil)
+				rdmaMgrMock.On("RenameRdmaDev", "mlx5_5", "rdma0").Return(syscall.EEXIST)
+				rdmaMgrMock.On("RenameRdmaDev", "mlx5_5", "rdma1").Return(nil)
+				rdmaMgrMock.On("RenameRdmaDev", "rdma1", "mlx5_5").Return(fmt.Errorf("rename error")).Run(recordCall)
+				rdmaMgrMock.On("RenameRdmaDev", "rdma0", "mlx5_4").Return(nil).Run(recordCall)
+				rdmaMgrMock.On("MoveRdmaDevToNs", mock.Anything, currNs).Return(nil).Run(recordCall)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), mock.Anything).
+					Return(fmt.Errorf("save error"))
+				err := plugin.CmdAdd(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("save error"))
+				Expect(err.Error()).To(ContainSubstring("failed to undo rename of RDMA device mlx5_5 to rdma1"))
+				Expect(calls).To(Equal([]string{"rdma1", "mlx5_5", "rdma0", "mlx5_4"}))
+				stateCacheMock.AssertNotCalled(t, "Delete", mock.Anything)
+			})
+			It("Should move the RDMA device matching rdmaDevPattern", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.RdmaDevPattern = "_5$"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_5", cns).Return(nil)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				expectedState := generateRdmaNetState(pciDev, "mlx5_5", "mlx5_5")
+				setRdmaNetStateAttachment(&expectedState, netName, cid, cIfname, cnsPath)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), &expectedState).Return(nil)
+				err := plugin.CmdAdd(&args)
+				Expect(err).ToNot(HaveOccurred())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should move the RDMA device with rdmaDevPort", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.RdmaDevPort = 2
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("GetRdmaDevPorts", "mlx5_4").Return([]string{"1"})
+				rdmaMgrMock.On("GetRdmaDevPorts", "mlx5_5").Return([]string{"1", "2"})
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_5", cns).Return(nil)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				expectedState := generateRdmaNetState(pciDev, "mlx5_5", "mlx5_5")
+				setRdmaNetStateAttachment(&expectedState, netName, cid, cIfname, cnsPath)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), &expectedState).Return(nil)
+				err := plugin.CmdAdd(&args)
+				Expect(err).ToNot(HaveOccurred())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should fail if container RDMA device name has no index template", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.AllRdmaDevs = true
+				netconf.ContainerRdmaDevName = "rdma_{ifname}"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+			})
+		})
+		Context("RDMA device provided", func() {
+			netName := "rdma-net"
+			cIfname := "net1"
+			cid := "a1b2c3d4e5f6"
+			cnsPath := "/proc/12444/ns/net"
+
+			JustBeforeEach(func() {
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+			})
+
+			expectRdmaDevMoved := func(rdmaDev string) {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				rdmaMgrMock.On("WaitRdmaDev", rdmaDev, anyTimeout).Return(nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				expectedState := generateRdmaNetState("", rdmaDev, rdmaDev)
+				setRdmaNetStateAttachment(&expectedState, netName, cid, cIfname, cnsPath)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), &expectedState).Return(nil)
+			}
+
+			It("Should move RDMA device provided in network configuration to Namespace", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, "")
+				netconf.RdmaDevice = "mlx5_7"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				expectRdmaDevMoved("mlx5_7")
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+				rdmaMgrMock.AssertNotCalled(t, "WaitRdmaDevsForPciDev", mock.Anything, mock.Anything)
+			})
+			It("Should prefer RDMA device provided in CNI_ARGS over network configuration", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, "")
+				netconf.RdmaDevice = "mlx5_7"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				args.Args = "RdmaDevice=mlx5_8"
+				expectRdmaDevMoved("mlx5_8")
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should prefer RDMA device provided in runtimeConfig over CNI_ARGS", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, "")
+				netconf.RdmaDevice = "mlx5_7"
+				netconf.RuntimeConfig.RdmaDevice = "mlx5_9"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				args.Args = "RdmaDevice=mlx5_8"
+				expectRdmaDevMoved("mlx5_9")
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should fail if RDMA device does not exist", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, "")
+				netconf.RdmaDevice = "mlx5_7"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				rdmaMgrMock.On("WaitRdmaDev", "mlx5_7", anyTimeout).Return(fmt.Errorf("error"))
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+		})
+		Context("Device attached to another container", func() {
+			pciDev := "0000:04:00.5"
+			netName := "rdma-net"
+			rdmaDev := "mlx5_4"
+			cIfname := "net1"
+			cid := "a1b2c3d4e5f6"
+			cnsPath := "/proc/12444/ns/net"
+			ownerNsPath := "/proc/13555/ns/net"
+			var netconf rdmaTypes.RdmaNetConf
+
+			JustBeforeEach(func() {
+				netconf = generateNetConfCmdAdd(netName, cIfname, pciDev)
+				owner := generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
+				setRdmaNetStateAttachment(&owner, netName, "f6e5d4c3b2a1", cIfname, ownerNsPath)
+				cachedStates["owner-ref"] = owner
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+			})
+
+			It("Should fail naming the owning container if device ID is attached", func() {
+				attachments[pciDev] = []cache.StateRef{"owner-ref"}
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				err := plugin.CmdAdd(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("f6e5d4c3b2a1"))
+				rdmaMgrMock.AssertNotCalled(t, "WaitRdmaDevsForPciDev", pciDev, anyTimeout)
+				stateCacheMock.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+			})
+			It("Should fail naming the owning container if RDMA device is attached", func() {
+				attachments[rdmaDev] = []cache.StateRef{"owner-ref"}
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{rdmaDev}, nil)
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				err := plugin.CmdAdd(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("f6e5d4c3b2a1"))
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+			It("Should fail naming the owning container if provided RDMA device is attached", func() {
+				attachments[rdmaDev] = []cache.StateRef{"owner-ref"}
+				netconf.RdmaDevice = rdmaDev
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				err := plugin.CmdAdd(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("f6e5d4c3b2a1"))
+				rdmaMgrMock.AssertNotCalled(t, "WaitRdmaDev", rdmaDev, anyTimeout)
+			})
+			It("Should succeed if the owning container network namespace is gone", func() {
+				attachments[pciDev] = []cache.StateRef{"owner-ref"}
+				attachments[rdmaDev] = []cache.StateRef{"owner-ref"}
+				dummyNsMgr.goneNS = []string{ownerNsPath}
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{rdmaDev}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Save", cache.StateRef("some-ref"), mock.Anything).Return(nil)
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+			It("Should succeed if the owner did not record its namespace and RDMA device is in default namespace", func() {
+				attachments[pciDev] = []cache.StateRef{"legacy-owner-ref"}
+				attachments[rdmaDev] = []cache.StateRef{"legacy-owner-ref"}
+				cachedStates["legacy-owner-ref"] = generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{rdmaDev}, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{rdmaDev}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Save", cache.StateRef("some-ref"), mock.Anything).Return(nil)
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+			It("Should fail if the owner did not record its namespace and RDMA device is not in default namespace", func() {
+				attachments[pciDev] = []cache.StateRef{"legacy-owner-ref"}
+				cachedStates["legacy-owner-ref"] = generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{})
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+			It("Should succeed if the attachment is of the same container", func() {
+				attachments[pciDev] = []cache.StateRef{"some-ref"}
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{rdmaDev}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Save", cache.StateRef("some-ref"), mock.Anything).Return(nil)
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+			})
+		})
+		Context("RDMA device already attached by a previous ADD", func() {
+			pciDev := "0000:04:00.5"
+			netName := "rdma-net"
+			rdmaDev := "mlx5_4"
+			cIfname := "net1"
+			cid := "a1b2c3d4e5f6"
+			cnsPath := "/proc/12444/ns/net"
+			var args skel.CmdArgs
+
+			JustBeforeEach(func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				args = generateArgs(cnsPath, cid, cIfname, &netconf)
+				cached := generateRdmaNetState(pciDev, rdmaDev, "rdma0")
+				setRdmaNetStateAttachment(&cached, netName, cid, cIfname, cnsPath)
+				cachedStates["some-ref"] = cached
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+			})
+
+			It("Should succeed without moving RDMA device if it is in Namespace", func() {
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"rdma0"}, nil).Once()
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil).Once()
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				rdmaMgrMock.AssertNotCalled(t, "WaitRdmaDevsForPciDev", mock.Anything, mock.Anything)
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+				stateCacheMock.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+			})
+			It("Should attach RDMA device again if it is not in Namespace", func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{}, nil).Once()
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{rdmaDev}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Save", cache.StateRef("some-ref"), mock.Anything).Return(nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should attach RDMA device again if it was attached to another Namespace", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				args = generateArgs("/proc/14666/ns/net", cid, cIfname, &netconf)
+				cns, _ := dummyNsMgr.GetNS("/proc/14666/ns/net")
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{rdmaDev}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Save", cache.StateRef("some-ref"), mock.Anything).Return(nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				rdmaMgrMock.AssertNotCalled(t, "GetRdmaDevs")
+			})
+		})
+		Context("Shared mode", func() {
+			pciDev := "0000:04:00.5"
+			netName := "rdma-net"
+			rdmaDev := "mlx5_4"
+			cIfname := "net1"
+			cid := "a1b2c3d4e5f6"
+			cnsPath := "/proc/12444/ns/net"
+
+			It("Should record RDMA device visible in Namespace without moving it", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Mode = rdmaTypes.RdmaNetModeShared
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{rdmaDev}, nil)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0", rdmaDev}, nil)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				expectedState := generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
+				setRdmaNetStateAttachment(&expectedState, netName, cid, cIfname, cnsPath)
+				expectedState.Mode = rdmaTypes.RdmaNetModeShared
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), &expectedState).Return(nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+				rdmaMgrMock.AssertNotCalled(t, "GetSystemRdmaMode")
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+			It("Should fail if RDMA device is not visible in Namespace", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Mode = rdmaTypes.RdmaNetModeShared
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{rdmaDev}, nil)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+				stateCacheMock.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+			})
+			It("Should fail if container RDMA device name is provided", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Mode = rdmaTypes.RdmaNetModeShared
+				netconf.ContainerRdmaDevName = "rdma{index}"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+			})
+			It("Should fail on invalid mode", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Mode = "isolated"
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).ToNot(Succeed())
+			})
+		})
+		Context("DeviceID not provided in network configuration", func() {
+			pciDev := "0000:04:00.5"
+			netName := "rdma-net"
+			rdmaDev := "mlx5_4"
+			cIfname := "net1"
+			cid := "a1b2c3d4e5f6"
+			cnsPath := "/proc/12444/ns/net"
+			devInfo := `{"type":"pci","version":"1.1.0","pci":{"pci-address":"` + pciDev + `"}}`
+
+			JustBeforeEach(func() {
+				cns, _ := dummyNsMgr.GetNS(cnsPath)
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				rdmaMgrMock.On("WaitRdmaDevsForPciDev", pciDev, anyTimeout).Return([]string{rdmaDev}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				expectedState := generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
+				setRdmaNetStateAttachment(&expectedState, netName, cid, cIfname, cnsPath)
+				stateCacheMock.On("Save", mock.AnythingOfType("cache.StateRef"), &expectedState).Return(nil)
+
+				devInfoCNIDir := utils.DevInfoCNIDir
+				utils.DevInfoCNIDir = GinkgoT().TempDir()
+				DeferCleanup(func() { utils.DevInfoCNIDir = devInfoCNIDir })
+			})
+
+			It("Should use DeviceID provided in runtimeConfig", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, "")
+				netconf.RuntimeConfig.DeviceID = pciDev
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should use DeviceID from device-info file of the network attachment", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, "")
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				devInfoPath := utils.GetCNIDeviceInfoPath(netName, cid, cIfname)
+				Expect(os.WriteFile(devInfoPath, []byte(devInfo), 0o600)).To(Succeed())
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should derive DeviceID from container network device", func() {
+				netconf := generateNetConfCmdAdd(netName, cIfname, "")
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				rdmaMgrMock.On("GetNetdevParentDev", cIfname).Return(pciDev, nil)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should use DeviceID from device-info file provided in runtimeConfig", func() {
+				devInfoPath := filepath.Join(utils.DevInfoCNIDir, "device.json")
+				Expect(os.WriteFile(devInfoPath, []byte(devInfo), 0o600)).To(Succeed())
+				netconf := generateNetConfCmdAdd(netName, cIfname, "")
+				netconf.RuntimeConfig.CNIDeviceInfoFile = devInfoPath
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdAdd(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+		})
+		// TODO(adrian): Add additional tests to cover bad flows / differen network configurations
+	})
+
+	Describe("Test pkeyGrants()", func() {
+		DescribeTable("Should grant membership of the same partition, full membership only if requested",
+			func(pkey, requested uint16, expected bool) {
+				Expect(pkeyGrants(pkey, requested)).To(Equal(expected))
+			},
+			Entry("full member, full membership requested", uint16(0x8001), uint16(0x8001), true),
+			Entry("full member, limited membership requested", uint16(0x8001), uint16(0x0001), true),
+			Entry("limited member, limited membership requested", uint16(0x0001), uint16(0x0001), true),
+			Entry("limited member, full membership requested", uint16(0x0001), uint16(0x8001), false),
+			Entry("another partition", uint16(0x8002), uint16(0x8001), false),
+		)
+	})
+
+	Describe("Test addRdmaDevInterface()", func() {
+		It("Should add RDMA device interface to the result for every CNI version", func() {
+			netconf := generateNetConfCmdAdd("rdma-net", "net1", "0000:04:00.5")
+			Expect(cniversion.ParsePrevResult(&netconf.NetConf)).To(Succeed())
+			result, err := current.NewResultFromResult(netconf.PrevResult)
+			Expect(err).ToNot(HaveOccurred())
+			rdmaDevIface := newRdmaDevInterface("mlx5_4", "0000:04:00.5", "/proc/12444/ns/net")
+			addRdmaDevInterface(result, rdmaDevIface)
+			addRdmaDevInterface(result, rdmaDevIface)
+			Expect(result.Interfaces).To(HaveLen(2))
+			Expect(*result.Interfaces[1]).To(Equal(current.Interface{
+				Name: "mlx5_4", Sandbox: "/proc/12444/ns/net", PciID: "0000:04:00.5"}))
+
+			for _, ver := range []string{"0.3.1", "0.4.0", "1.0.0", "1.1.0"} {
+				res, err := result.GetAsVersion(ver)
+				Expect(err).ToNot(HaveOccurred())
+				bytes, err := json.Marshal(res)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(bytes)).To(ContainSubstring(`{"name":"mlx5_4"`), "CNI version %s", ver)
+			}
+		})
+	})
+
+	Describe("Test CmdDel()", func() {
+		Context("GUID was set on ADD", func() {
+			It("Should restore previous GUID of VF after moving RDMA device back", func() {
+				netName := "rdma-net"
+				cid := "a1b2c3d4e5f6"
+				cIfname := "net1"
+				cnsPath := "/proc/12444/ns/net"
+				currNs, _ := dummyNsMgr.GetCurrentNS()
+				prevGUID, _ := net.ParseMAC("98:03:9b:03:00:9e:e3:5e")
+				rdmaState := generateRdmaNetState("0000:04:00.5", "mlx5_4", "mlx5_4")
+				rdmaState.GUID = "00:11:22:33:44:55:66:77"
+				rdmaState.PrevGUID = "98:03:9b:03:00:9e:e3:5e"
+				netconf := generateNetConfCmdDel(netName)
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
+					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+					*arg = rdmaState
+				})
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				var calls []string
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_4", currNs).Return(nil).Run(func(_ mock.Arguments) {
+					calls = append(calls, "MoveRdmaDevToNs")
+				})
+				rdmaMgrMock.On("SetVfGUID", "0000:04:00.5", prevGUID).Return(nil).Run(func(_ mock.Arguments) {
+					calls = append(calls, "SetVfGUID")
+				})
+				stateCacheMock.On("Delete", mock.AnythingOfType("cache.StateRef")).Return(nil)
+				Expect(plugin.CmdDel(&args)).To(Succeed())
+				Expect(calls).To(Equal([]string{"MoveRdmaDevToNs", "SetVfGUID"}))
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should fail and keep cache entry if previous GUID cannot be restored", func() {
+				netName := "rdma-net"
+				rdmaState := generateRdmaNetState("0000:04:00.5", "mlx5_4", "mlx5_4")
+				rdmaState.PrevGUID = "98:03:9b:03:00:9e:e3:5e"
+				netconf := generateNetConfCmdDel(netName)
+				args := generateArgs("/proc/12444/ns/net", "a1b2c3d4e5f6", "net1", &netconf)
+				stateCacheMock.On("GetStateRef", netName, "a1b2c3d4e5f6", "net1").Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
+					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+					*arg = rdmaState
+				})
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_4", mock.Anything).Return(nil)
+				rdmaMgrMock.On("SetVfGUID", "0000:04:00.5", mock.Anything).Return(fmt.Errorf("error"))
+				Expect(plugin.CmdDel(&args)).ToNot(Succeed())
+				stateCacheMock.AssertNotCalled(t, "Delete", mock.Anything)
+			})
+		})
+		Context("RDMA device in Namespace is not the cached one", func() {
+			It("Should succeed without moving it and delete cache entry", func() {
+				netName := "rdma-net"
+				cid := "a1b2c3d4e5f6"
+				cIfname := "net1"
+				cnsPath := "/proc/12444/ns/net"
+				rdmaState := generateRdmaNetState("0000:04:00.5", "mlx5_4", "mlx5_4")
+				rdmaState.RdmaDevs[0].NodeGUID = "98:03:9b:03:00:9e:e3:5e"
+				netconf := generateNetConfCmdDel(netName)
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
+					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+					*arg = rdmaState
+				})
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("GetRdmaDevInfo", "mlx5_4").Return(
+					&rdmaTypes.RdmaDevInfo{Name: "mlx5_4", NodeGUID: "98:03:9b:03:00:9e:e3:5f"}, nil)
+				stateCacheMock.On("Delete", mock.AnythingOfType("cache.StateRef")).Return(nil)
+				Expect(plugin.CmdDel(&args)).To(Succeed())
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+				stateCacheMock.AssertExpectations(t)
+			})
+		})
+		Context("RDMA device already moved back by a previous DEL", func() {
+			It("Should restore previous GUID of VF without moving RDMA device and delete cache entry", func() {
+				rdmaState := generateRdmaNetState("0000:04:00.5", "mlx5_4", "mlx5_4")
+				rdmaState.RdmaDevs[0].NodeGUID = "00:11:22:33:44:55:66:77"
+				rdmaState.GUID = "00:11:22:33:44:55:66:77"
+				rdmaState.PrevGUID = "98:03:9b:03:00:9e:e3:5e"
+				prevGUID, _ := net.ParseMAC(rdmaState.PrevGUID)
+				netconf := generateNetConfCmdDel("rdma-net")
+				args := generateArgs("/proc/12444/ns/net", "a1b2c3d4e5f6", "net1", &netconf)
+				stateCacheMock.On("GetStateRef", "rdma-net", "a1b2c3d4e5f6", "net1").Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
+					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+					*arg = rdmaState
+				})
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0", "mlx5_4"}, nil)
+				rdmaMgrMock.On("GetRdmaDevInfo", "mlx5_4").Return(
+					&rdmaTypes.RdmaDevInfo{Name: "mlx5_4", NodeGUID: "00:11:22:33:44:55:66:77"}, nil)
+				rdmaMgrMock.On("SetVfGUID", "0000:04:00.5", prevGUID).Return(nil)
+				stateCacheMock.On("Delete", cache.StateRef("some-ref")).Return(nil)
+				Expect(plugin.CmdDel(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+			It("Should restore original name of RDMA device without moving it and delete cache entry", func() {
+				rdmaState := generateRdmaNetState("0000:04:00.5", "mlx5_4", "rdma0")
+				netconf := generateNetConfCmdDel("rdma-net")
+				args := generateArgs("/proc/12444/ns/net", "a1b2c3d4e5f6", "net1", &netconf)
+				stateCacheMock.On("GetStateRef", "rdma-net", "a1b2c3d4e5f6", "net1").Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
+					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+					*arg = rdmaState
+				})
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0", "rdma0"}, nil)
+				rdmaMgrMock.On("RenameRdmaDev", "rdma0", "mlx5_4").Return(nil)
+				stateCacheMock.On("Delete", cache.StateRef("some-ref")).Return(nil)
+				Expect(plugin.CmdDel(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+		})
+		Context("Valid configuration provided", func() {
+			It("Should succeed and move Rdma device associated with PCI net device back to sandbox namespace", func() {
+				pciDev := "0000:04:00.5"
+				netName := "rdma-net"
+				rdmaDev := "mlx5_4"
+				cIfname := "net1"
+				cid := "a1b2c3d4e5f6"
+				cnsPath := "/proc/12444/ns/net"
+				cns, _ := dummyNsMgr.GetCurrentNS()
+				rdmaState := generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
+				netconf := generateNetConfCmdDel(netName)
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
+					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+					*arg = rdmaState
+				})
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Delete", mock.AnythingOfType("cache.StateRef")).Return(nil)
+				err := plugin.CmdDel(&args)
+				Expect(err).ToNot(HaveOccurred())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+				Expect(locker.lockedRefs).To(Equal([]cache.StateRef{"some-ref"}))
+				Expect(locker.nodeLocks).To(Equal(1))
+				Expect(locker.removedRefs).To(Equal([]cache.StateRef{"some-ref"}))
+			})
+			It("Should succeed and move Rdma device associated with auxiliary device back to sandbox namespace", func() {
+				auxDev := "mlx5_core.sf.6"
+				netName := "rdma-net"
+				rdmaDev := "mlx5_6"
+				cIfname := "net2"
+				cid := "a1b2c3d4e5f6"
+				cnsPath := "/proc/12444/ns/net"
+				cns, _ := dummyNsMgr.GetCurrentNS()
+				rdmaState := generateRdmaNetState(auxDev, rdmaDev, rdmaDev)
+				netconf := generateNetConfCmdDel(netName)
+				args := generateArgs(cnsPath, cid, cIfname, &netconf)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
+					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+					*arg = rdmaState
+				})
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", rdmaDev, cns).Return(nil)
+				stateCacheMock.On("Delete", mock.AnythingOfType("cache.StateRef")).Return(nil)
+				err := plugin.CmdDel(&args)
+				Expect(err).ToNot(HaveOccurred())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+		})
+		Context("RDMA device shared with container namespace", func() {
+			It("Should delete cache entry without moving RDMA device", func() {
+				netName := "rdma-net"
+				cIfname := "net1"
+				cid := "a1b2c3d4e5f6"
+				rdmaState := generateRdmaNetState("0000:04:00.5", "mlx5_4", "mlx5_4")
+				rdmaState.Mode = rdmaTypes.RdmaNetModeShared
+				netconf := generateNetConfCmdDel(netName)
+				args := generateArgs("/proc/12444/ns/net", cid, cIfname, &netconf)
+				stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
+					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+					*arg = rdmaState
+				})
+				stateCacheMock.On("Delete", mock.AnythingOfType("cache.StateRef")).Return(nil)
+				Expect(plugin.CmdDel(&args)).To(Succeed())
+				stateCacheMock.AssertExpectations(t)
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+		})
+		Context("Container namespace is gone", func() {
+			It("Should verify RDMA device is in default namespace and delete cache entry", func() {
+				cnsPath := "/proc/12444/ns/net"
+				rdmaState := generateRdmaNetState("0000:04:00.5", "mlx5_4", "rdma0")
+				setRdmaNetStateAttachment(&rdmaState, "rdma-net", "a1b2c3d4e5f6", "net1", cnsPath)
+				netconf := generateNetConfCmdDel("rdma-net")
+				args := generateArgs(cnsPath, "a1b2c3d4e5f6", "net1", &netconf)
+				dummyNsMgr.goneNS = []string{cnsPath}
+				stateCacheMock.On("GetStateRef", "rdma-net", "a1b2c3d4e5f6", "net1").Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
+					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+					*arg = rdmaState
+				})
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"rdma0"}, nil)
+				rdmaMgrMock.On("RenameRdmaDev", "rdma0", "mlx5_4").Return(nil)
+				stateCacheMock.On("Delete", cache.StateRef("some-ref")).Return(nil)
+				Expect(plugin.CmdDel(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+				rdmaMgrMock.AssertNotCalled(t, "MoveRdmaDevToNs", mock.Anything, mock.Anything)
+			})
+		})
+		Context("Container namespace not provided", func() {
+			It("Should move RDMA device back from the cached namespace and delete cache entry", func() {
+				rdmaState := generateRdmaNetState("0000:04:00.5", "mlx5_4", "mlx5_4")
+				setRdmaNetStateAttachment(&rdmaState, "rdma-net", "a1b2c3d4e5f6", "net1", "/proc/12444/ns/net")
+				netconf := generateNetConfCmdDel("rdma-net")
+				args := generateArgs("", "a1b2c3d4e5f6", "net1", &netconf)
+				cns, _ := dummyNsMgr.GetCurrentNS()
+				stateCacheMock.On("GetStateRef", "rdma-net", "a1b2c3d4e5f6", "net1").Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
+					arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+					*arg = rdmaState
+				})
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_4", cns).Return(nil)
+				stateCacheMock.On("Delete", cache.StateRef("some-ref")).Return(nil)
+				Expect(plugin.CmdDel(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+		})
+		Context("Cache entry does not exist", func() {
+			JustBeforeEach(func() {
+				stateCacheMock.On("GetStateRef", "rdma-net", "a1b2c3d4e5f6", "net1").Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(fmt.Errorf("error. %w", os.ErrNotExist))
+			})
+
+			It("Should look up RDMA devices of device ID in default namespace and succeed", func() {
+				netconf := generateNetConfCmdDel("rdma-net")
+				netconf.DeviceID = "0000:04:00.5"
+				args := generateArgs("/proc/12444/ns/net", "a1b2c3d4e5f6", "net1", &netconf)
+				rdmaMgrMock.On("GetRdmaDevsForPciDev", "0000:04:00.5").Return([]string{"mlx5_4"})
+				Expect(plugin.CmdDel(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertNotCalled(t, "Delete", mock.Anything)
+			})
+			It("Should move provided RDMA device back to default namespace and succeed", func() {
+				netconf := generateNetConfCmdDel("rdma-net")
+				netconf.RdmaDevice = "mlx5_4"
+				args := generateArgs("/proc/12444/ns/net", "a1b2c3d4e5f6", "net1", &netconf)
+				cns, _ := dummyNsMgr.GetCurrentNS()
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_4", cns).Return(nil)
+				Expect(plugin.CmdDel(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+		})
+		Context("Cache entry is corrupted", func() {
+			It("Should move provided RDMA device back to default namespace and succeed", func() {
+				stateCacheMock.On("GetStateRef", "rdma-net", "a1b2c3d4e5f6", "net1").Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(fmt.Errorf("error. %w", cache.ErrCorruptedState))
+				netconf := generateNetConfCmdDel("rdma-net")
+				netconf.RdmaDevice = "mlx5_4"
+				args := generateArgs("/proc/12444/ns/net", "a1b2c3d4e5f6", "net1", &netconf)
+				cns, _ := dummyNsMgr.GetCurrentNS()
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_4", cns).Return(nil)
+				Expect(plugin.CmdDel(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertNotCalled(t, "Delete", mock.Anything)
+			})
+		})
+		Context("Cache entry cannot be loaded", func() {
+			It("Should move provided RDMA device back to default namespace and keep the cache entry", func() {
+				stateCacheMock.On("GetStateRef", "rdma-net", "a1b2c3d4e5f6", "net1").Return(cache.StateRef("some-ref"))
+				stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+					mock.AnythingOfType("*types.RdmaNetState")).Return(
+					fmt.Errorf("error. %w", rdmaTypes.ErrUnsupportedStateVersion))
+				netconf := generateNetConfCmdDel("rdma-net")
+				netconf.RdmaDevice = "mlx5_4"
+				args := generateArgs("/proc/12444/ns/net", "a1b2c3d4e5f6", "net1", &netconf)
+				cns, _ := dummyNsMgr.GetCurrentNS()
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_4", cns).Return(nil)
+				Expect(plugin.CmdDel(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertNotCalled(t, "Delete", mock.Anything)
+				Expect(locker.removedRefs).To(BeEmpty())
+			})
+		})
+		// TODO(adrian): Add additional tests to cover bad flows / different network configurations
+	})
+
+	Describe("Test CmdGC()", func() {
+		var (
+			netName string
+			cnsPath string
+			states  map[cache.StateRef]rdmaTypes.RdmaNetState
+			// Cache entries created before the attachment was recorded in state
+			unknownRefs []cache.StateRef
+			sysMode     string
+		)
+
+		JustBeforeEach(func() {
+			netName = "rdma-net"
+			cnsPath = "/proc/12444/ns/net"
+			valid := generateRdmaNetState("0000:04:00.5", "mlx5_4", "mlx5_4")
+			setRdmaNetStateAttachment(&valid, netName, "a1b2c3d4e5f6", "net1", cnsPath)
+			stale := generateRdmaNetState("0000:04:00.6", "mlx5_5", "mlx5_5")
+			setRdmaNetStateAttachment(&stale, netName, "f6e5d4c3b2a1", "net1", cnsPath)
+			states = map[cache.StateRef]rdmaTypes.RdmaNetState{"valid-ref": valid, "stale-ref": stale}
+
+			unknownRefs = nil
+			sysMode = rdma.RdmaSysModeExclusive
+			stateCacheMock.On("ListByNetwork", netName).Return([]cache.StateRef{"valid-ref", "stale-ref"}, nil)
+			stateCacheMock.On("ListByNetwork", "").Return(func(string) ([]cache.StateRef, error) {
+				return unknownRefs, nil
+			})
+			rdmaMgrMock.On("GetSystemRdmaMode").Return(func() (string, error) { return sysMode, nil }).Maybe()
+			stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+				mock.AnythingOfType("*types.RdmaNetState")).Return(nil).Run(func(args mock.Arguments) {
+				arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+				*arg = states[args.Get(0).(cache.StateRef)]
+			})
+		})
+
+		Context("Stale attachment with RDMA device in default namespace", func() {
+			It("Should delete only the stale cache entry", func() {
+				netconf := generateNetConfCmdGC(netName, []types.GCAttachment{
+					{ContainerID: "a1b2c3d4e5f6", IfName: "net1"}})
+				args := generateArgs("", "", "", &netconf)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_5"}, nil)
+				stateCacheMock.On("Delete", cache.StateRef("stale-ref")).Return(nil)
+				Expect(plugin.CmdGC(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+				Expect(locker.removedRefs).To(Equal([]cache.StateRef{"stale-ref"}))
+			})
+		})
+		Context("Stale attachment with RDMA device in container namespace", func() {
+			It("Should move RDMA device to default namespace and delete the stale cache entry", func() {
+				netconf := generateNetConfCmdGC(netName, []types.GCAttachment{
+					{ContainerID: "a1b2c3d4e5f6", IfName: "net1"}})
+				args := generateArgs("", "", "", &netconf)
+				currNs, _ := dummyNsMgr.GetCurrentNS()
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_5", currNs).Return(nil)
+				stateCacheMock.On("Delete", cache.StateRef("stale-ref")).Return(nil)
+				Expect(plugin.CmdGC(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+		})
+		Context("Failure to restore RDMA device of stale attachment", func() {
+			It("Should fail and keep the stale cache entry", func() {
+				netconf := generateNetConfCmdGC(netName, []types.GCAttachment{
+					{ContainerID: "a1b2c3d4e5f6", IfName: "net1"}})
+				args := generateArgs("", "", "", &netconf)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{}, nil)
+				rdmaMgrMock.On("MoveRdmaDevToNs", "mlx5_5", mock.Anything).Return(fmt.Errorf("error"))
+				Expect(plugin.CmdGC(&args)).ToNot(Succeed())
+				stateCacheMock.AssertNotCalled(t, "Delete", mock.Anything)
+				Expect(locker.removedRefs).To(BeEmpty())
+			})
+		})
+		Context("Cache entry created before the attachment was recorded in state", func() {
+			var args skel.CmdArgs
+			JustBeforeEach(func() {
+				netconf := generateNetConfCmdGC(netName, []types.GCAttachment{
+					{ContainerID: "a1b2c3d4e5f6", IfName: "net1"}, {ContainerID: "f6e5d4c3b2a1", IfName: "net1"}})
+				args = generateArgs("", "", "", &netconf)
+				unknownRefs = []cache.StateRef{"rdma-net-0a1b2c3d4e5f-net1"}
+				states["rdma-net-0a1b2c3d4e5f-net1"] = generateRdmaNetState("0000:04:00.7", "mlx5_7", "mlx5_7")
+			})
+			It("Should delete the cache entry if its RDMA device is in default namespace", func() {
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_7"}, nil)
+				stateCacheMock.On("Delete", cache.StateRef("rdma-net-0a1b2c3d4e5f-net1")).Return(nil)
+				Expect(plugin.CmdGC(&args)).To(Succeed())
+				stateCacheMock.AssertExpectations(t)
+				Expect(locker.removedRefs).To(Equal([]cache.StateRef{"rdma-net-0a1b2c3d4e5f-net1"}))
+			})
+			It("Should keep the cache entry if its RDMA device is not in default namespace", func() {
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				Expect(plugin.CmdGC(&args)).To(Succeed())
+				stateCacheMock.AssertNotCalled(t, "Delete", mock.Anything)
+			})
+			It("Should keep the cache entry if RDMA subsystem mode is shared", func() {
+				sysMode = rdma.RdmaSysModeShared
+				Expect(plugin.CmdGC(&args)).To(Succeed())
+				stateCacheMock.AssertNotCalled(t, "ListByNetwork", "")
+				stateCacheMock.AssertNotCalled(t, "Delete", mock.Anything)
+			})
+		})
+	})
+
+	Describe("Test CmdStatus()", func() {
+		var args skel.CmdArgs
+
+		JustBeforeEach(func() {
+			netconf := generateNetConfCmdGC("rdma-net", nil)
+			args = generateArgs("", "", "", &netconf)
+		})
+
+		Context("RDMA subsystem is ready", func() {
+			It("Should succeed", func() {
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				stateCacheMock.On("EnsureWritable").Return(nil)
+				Expect(plugin.CmdStatus(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+		})
+		Context("RDMA netlink family is not reachable", func() {
+			It("Should fail with plugin not available error", func() {
+				rdmaMgrMock.On("GetRdmaDevs").Return(nil, fmt.Errorf("error"))
+				err := plugin.CmdStatus(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*types.Error).Code).To(Equal(errPluginNotAvailable))
+			})
+		})
+		Context("RDMA subsystem mode is shared", func() {
+			It("Should fail with plugin not available error", func() {
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeShared, nil)
+				err := plugin.CmdStatus(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*types.Error).Code).To(Equal(errPluginNotAvailable))
+			})
+		})
+		Context("RDMA subsystem mode is shared and autoExclusiveMode is set", func() {
+			It("Should switch RDMA subsystem mode to exclusive and succeed", func() {
+				netconf := generateNetConfCmdGC("rdma-net", nil)
+				netconf.AutoExclusiveMode = true
+				args = generateArgs("", "", "", &netconf)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeShared, nil)
+				rdmaMgrMock.On("SetSystemRdmaMode", rdma.RdmaSysModeExclusive).Return(nil)
+				stateCacheMock.On("EnsureWritable").Return(nil)
+				Expect(plugin.CmdStatus(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+		})
+		Context("State cache is not writable", func() {
+			It("Should fail with plugin not available error", func() {
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				stateCacheMock.On("EnsureWritable").Return(fmt.Errorf("error"))
+				err := plugin.CmdStatus(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*types.Error).Code).To(Equal(errPluginNotAvailable))
+			})
+		})
+		Context("State directory is provided", func() {
+			It("Should use the state cache in the state directory", func() {
+				netconf := generateNetConfCmdGC("rdma-net", nil)
+				netconf.StateDir = "/opt/rdma-cni/state"
+				args = generateArgs("", "", "", &netconf)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				stateCacheMock.On("EnsureWritable").Return(nil)
+				Expect(plugin.CmdStatus(&args)).To(Succeed())
+				Expect(stateDirs).To(Equal([]string{"/opt/rdma-cni/state"}))
+				stateCacheMock.AssertNotCalled(t, "EnsurePersistent")
+			})
+			It("Should fail if the state directory is not an absolute path", func() {
+				netconf := generateNetConfCmdGC("rdma-net", nil)
+				netconf.StateDir = "state"
+				args = generateArgs("", "", "", &netconf)
+				Expect(plugin.CmdStatus(&args)).ToNot(Succeed())
+			})
+		})
+		Context("Persistent state directory is required", func() {
+			JustBeforeEach(func() {
+				netconf := generateNetConfCmdGC("rdma-net", nil)
+				netconf.RequirePersistentStateDir = true
+				args = generateArgs("", "", "", &netconf)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil)
+				rdmaMgrMock.On("GetSystemRdmaMode").Return(rdma.RdmaSysModeExclusive, nil)
+				stateCacheMock.On("EnsureWritable").Return(nil)
+			})
+			It("Should succeed if the state directory is persistent", func() {
+				stateCacheMock.On("EnsurePersistent").Return(nil)
+				Expect(plugin.CmdStatus(&args)).To(Succeed())
+				stateCacheMock.AssertExpectations(t)
+			})
+			It("Should fail with plugin not available error if the state directory is not persistent", func() {
+				stateCacheMock.On("EnsurePersistent").Return(cache.ErrNotPersistent)
+				err := plugin.CmdStatus(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*types.Error).Code).To(Equal(errPluginNotAvailable))
+			})
+		})
+	})
+
+	Describe("Test CmdCheck()", func() {
+		var (
+			pciDev    string
+			netName   string
+			rdmaDev   string
+			cIfname   string
+			cid       string
+			cnsPath   string
+			rdmaState rdmaTypes.RdmaNetState
+			args      skel.CmdArgs
+		)
+
+		JustBeforeEach(func() {
+			pciDev = "0000:04:00.5"
+			netName = "rdma-net"
+			rdmaDev = "mlx5_4"
+			cIfname = "net1"
+			cid = "a1b2c3d4e5f6"
+			cnsPath = "/proc/12444/ns/net"
+			rdmaState = generateRdmaNetState(pciDev, rdmaDev, rdmaDev)
+			netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+			args = generateArgs(cnsPath, cid, cIfname, &netconf)
+		})
+
+		mockStateLoad := func(retErr error) {
+			stateCacheMock.On("GetStateRef", netName, cid, cIfname).Return(cache.StateRef("some-ref"))
+			stateCacheMock.On("Load", mock.AnythingOfType("cache.StateRef"),
+				mock.AnythingOfType("*types.RdmaNetState")).Return(retErr).Run(func(args mock.Arguments) {
+				arg := args.Get(1).(*rdmaTypes.RdmaNetState)
+				*arg = rdmaState
+			})
+		}
+
+		Context("RDMA device in container namespace", func() {
+			It("Should succeed", func() {
+				mockStateLoad(nil)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{rdmaDev}, nil).Once()
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0"}, nil).Once()
+				Expect(plugin.CmdCheck(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+				stateCacheMock.AssertExpectations(t)
+			})
+		})
+		Context("RDMA device not in container namespace", func() {
+			It("Should fail", func() {
+				mockStateLoad(nil)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{}, nil).Once()
+				err := plugin.CmdCheck(&args)
+				Expect(err).To(HaveOccurred())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+		})
+		Context("RDMA device also in default namespace", func() {
+			It("Should fail", func() {
+				mockStateLoad(nil)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{rdmaDev}, nil).Twice()
+				err := plugin.CmdCheck(&args)
+				Expect(err).To(HaveOccurred())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+		})
+		Context("RDMA device shared with container namespace", func() {
+			It("Should succeed if RDMA device is visible in container namespace", func() {
+				rdmaState.Mode = rdmaTypes.RdmaNetModeShared
+				netconf := generateNetConfCmdAdd(netName, cIfname, pciDev)
+				netconf.Mode = rdmaTypes.RdmaNetModeShared
+				args = generateArgs(cnsPath, cid, cIfname, &netconf)
+				mockStateLoad(nil)
+				rdmaMgrMock.On("GetRdmaDevs").Return([]string{"mlx5_0", rdmaDev}, nil).Once()
+				Expect(plugin.CmdCheck(&args)).To(Succeed())
+				rdmaMgrMock.AssertExpectations(t)
+			})
+			It("Should fail if mode does not match cached mode", func() {
+				rdmaState.Mode = rdmaTypes.RdmaNetModeShared
+				mockStateLoad(nil)
+				err := plugin.CmdCheck(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*types.Error).Code).To(Equal(types.ErrInvalidNetworkConfig))
+			})
+		})
+		Context("Cache entry does not exist", func() {
+			It("Should fail with unknown container error", func() {
+				mockStateLoad(fmt.Errorf("error"))
+				err := plugin.CmdCheck(&args)
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*types.Error).Code).To(Equal(types.ErrUnknownContainer))
+				stateCacheMock.AssertExpectations(t)
+			})
+		})
+		Context("Called without prevResult", func() {
+			It("Should fail", func() {
+				netconf := generateNetConfCmdDel(netName)
+				args = generateArgs(cnsPath, cid, cIfname, &netconf)
+				Expect(plugin.CmdCheck(&args)).ToNot(Succeed())
+			})
 		})
 	})
 })
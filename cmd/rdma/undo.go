@@ -0,0 +1,56 @@
+// Copyright 2025 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Step of an operation which was completed and can be undone
+type undoStep struct {
+	// Description of the completed step e.g "move of RDMA device mlx5_3 to namespace"
+	desc string
+	undo func() error
+}
+
+// Stack of completed steps of an operation, undone in reverse order if the operation fails
+type undoStack struct {
+	steps []undoStep
+}
+
+// Record a completed step along with the function undoing it
+func (us *undoStack) push(desc string, undo func() error) {
+	us.steps = append(us.steps, undoStep{desc: desc, undo: undo})
+}
+
+func (us *undoStack) empty() bool {
+	return len(us.steps) == 0
+}
+
+// Undo all completed steps in reverse order, emptying the stack. Failing to undo a step does not stop
+// undoing the previous ones, the returned error is err joined with the errors of all failed steps
+func (us *undoStack) rollback(err error) error {
+	errs := []error{err}
+	for i := len(us.steps) - 1; i >= 0; i-- {
+		if undoErr := us.steps[i].undo(); undoErr != nil {
+			errs = append(errs, fmt.Errorf("failed to undo %s. %v", us.steps[i].desc, undoErr))
+		}
+	}
+	us.steps = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,74 @@
+// Copyright 2025 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Undo stack", func() {
+	var (
+		undo  *undoStack
+		calls []string
+	)
+
+	JustBeforeEach(func() {
+		undo = &undoStack{}
+		calls = nil
+	})
+
+	pushStep := func(desc string, err error) {
+		undo.push(desc, func() error {
+			calls = append(calls, desc)
+			return err
+		})
+	}
+
+	Context("No completed steps", func() {
+		It("Should return the original error", func() {
+			origErr := errors.New("error")
+			Expect(undo.empty()).To(BeTrue())
+			Expect(undo.rollback(origErr)).To(MatchError(origErr))
+		})
+	})
+	Context("Completed steps", func() {
+		It("Should undo them in reverse order and empty the stack", func() {
+			pushStep("first", nil)
+			pushStep("second", nil)
+			Expect(undo.rollback(errors.New("error"))).To(MatchError("error"))
+			Expect(calls).To(Equal([]string{"second", "first"}))
+			Expect(undo.empty()).To(BeTrue())
+		})
+		It("Should undo all of them and join errors of the failed ones", func() {
+			origErr := errors.New("error")
+			undoErr := fmt.Errorf("undo error")
+			pushStep("first", undoErr)
+			pushStep("second", nil)
+			pushStep("third", undoErr)
+			err := undo.rollback(origErr)
+			Expect(calls).To(Equal([]string{"third", "second", "first"}))
+			Expect(errors.Is(err, origErr)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("failed to undo third. undo error"))
+			Expect(err.Error()).To(ContainSubstring("failed to undo first. undo error"))
+			Expect(err.Error()).ToNot(ContainSubstring("second"))
+		})
+	})
+})
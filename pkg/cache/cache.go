@@ -18,19 +18,41 @@ package cache
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/k8snetworkplumbingwg/rdma-cni/pkg/types"
 )
 
 const (
 	dirPerms  = 0o700
 	filePerms = 0o600
+	// Prefix of files in cache directory which are not cached states
+	hiddenFilePrefix = "."
+	writeProbeFile   = hiddenFilePrefix + "write-probe"
+	// Pattern of temporary files states are written to before being renamed to their final path
+	tmpFilePattern = hiddenFilePrefix + "tmp-*"
+	// Directory in cache directory corrupted states are moved to
+	quarantineDir = hiddenFilePrefix + "corrupted"
+	// StateDirEnv is the environment variable overriding CacheDir
+	StateDirEnv = "RDMA_CNI_STATE_DIR"
+	// File system magic numbers, see statfs(2)
+	ext4Magic  = 0xef53
+	tmpfsMagic = 0x01021994
+	ramfsMagic = 0x858458f6
 )
 
 var (
 	// CacheDir is used By default for caching CNI network state
 	CacheDir = "/var/lib/cni/rdma"
+	// ErrCorruptedState is returned when a cached state is corrupted, e.g partially written
+	ErrCorruptedState = errors.New("corrupted cache data")
+	// ErrNotPersistent is returned when cache directory is on a file system which does not survive reboot
+	ErrNotPersistent = errors.New("cache directory is not on a persistent file system")
 )
 
 type StateRef string
@@ -44,23 +66,49 @@ type StateCache interface {
 	Load(ref StateRef, state interface{}) error
 	// Delete state from cache
 	Delete(ref StateRef) error
+	// List references of all states in cache
+	List() ([]StateRef, error)
+	// List references of RDMA network states in cache attached for the given network
+	ListByNetwork(network string) ([]StateRef, error)
+	// List references of RDMA network states in cache attached for the given container ID
+	ListByContainerID(cid string) ([]StateRef, error)
+	// List references of RDMA network states in cache attached for the given PCI device ID
+	ListByDeviceID(deviceID string) ([]StateRef, error)
+	// List references of RDMA network states in cache the given RDMA device (sandbox name) is attached by
+	ListByRdmaDev(rdmaDev string) ([]StateRef, error)
+	// Ensure cache is writable
+	EnsureWritable() error
+	// Ensure cache is on a persistent file system, i.e not on tmpfs or ramfs
+	EnsurePersistent() error
 }
 
-// Create a new RDMA state Cache that will Save/Load state
-func NewStateCache() StateCache {
-	return &FsStateCache{basePath: CacheDir, fsOps: newFsOps()}
+// Create a new RDMA state Cache that will Save/Load state in the given directory.
+// If empty, the directory set in StateDirEnv environment variable is used, otherwise CacheDir
+func NewStateCache(stateDir string) StateCache {
+	if stateDir == "" {
+		stateDir = os.Getenv(StateDirEnv)
+	}
+	if stateDir == "" {
+		stateDir = CacheDir
+	}
+	return &FsStateCache{basePath: stateDir, fsOps: newFsOps(), locker: NewLocker()}
 }
 
 type FsStateCache struct {
 	basePath string
 	fsOps    FileSystemOps
+	// Serializes migration of states cached under legacy references
+	locker Locker
 }
 
 func (sc *FsStateCache) GetStateRef(network, cid, ifname string) StateRef {
-	return StateRef(strings.Join([]string{network, cid, ifname}, "-"))
+	return encodeStateRef(network, cid, ifname)
 }
 
 func (sc *FsStateCache) Save(ref StateRef, state interface{}) error {
+	if err := ValidateStateRef(ref); err != nil {
+		return err
+	}
 	sRef := string(ref)
 	bytes, err := json.Marshal(state)
 	if err != nil {
@@ -73,27 +121,128 @@ func (sc *FsStateCache) Save(ref StateRef, state interface{}) error {
 	}
 
 	path := filepath.Join(sc.basePath, sRef)
+	if err = sc.writeFileAtomic(path, bytes); err != nil {
+		return fmt.Errorf("failed to write cache data in the path(%q): %v", path, err)
+	}
+	return nil
+}
 
-	//nolint:gomnd
-	err = sc.fsOps.WriteFile(path, bytes, filePerms)
+// Write data to a temporary file which is then renamed to path, so path contains either
+// the previous or the new data even if interrupted
+func (sc *FsStateCache) writeFileAtomic(path string, data []byte) error {
+	tmpFile, err := sc.fsOps.CreateTemp(filepath.Dir(path), tmpFilePattern)
 	if err != nil {
-		return fmt.Errorf("failed to write cache data in the path(%q): %v", path, err)
+		return err
 	}
+	tmpPath := tmpFile.Name()
 
-	return err
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = sc.fsOps.Rename(tmpPath, path)
+	}
+	if err != nil {
+		_ = sc.fsOps.Remove(tmpPath)
+		return err
+	}
+	return sc.fsOps.SyncDir(filepath.Dir(path))
 }
 
 func (sc *FsStateCache) Load(ref StateRef, state interface{}) error {
+	if err := ValidateStateRef(ref); err != nil {
+		return err
+	}
 	sRef := string(ref)
 	path := filepath.Join(sc.basePath, sRef)
 	bytes, err := sc.fsOps.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		bytes, err = sc.migrateLegacyState(ref, err)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to read cache data in the path(%q): %v", path, err)
+		return fmt.Errorf("failed to read cache data in the path(%q): %w", path, err)
+	}
+	if !json.Valid(bytes) {
+		return sc.quarantine(ref)
 	}
 	return json.Unmarshal(bytes, state)
 }
 
+// Move state cached by previous versions under the legacy reference format to the given reference and read it.
+// Legacy references are ambiguous, the state is only adopted if it belongs to the given reference.
+// origErr is returned if no such state exists
+func (sc *FsStateCache) migrateLegacyState(ref StateRef, origErr error) ([]byte, error) {
+	network, cid, ifname, err := decodeStateRef(ref)
+	if err != nil {
+		return nil, origErr
+	}
+	legacyRef, err := legacyStateRef(network, cid, ifname)
+	if err != nil {
+		return nil, origErr
+	}
+	// Other references may share the same legacy reference
+	unlock, err := sc.locker.LockRef(legacyRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock legacy cache entry(%q): %v", legacyRef, err)
+	}
+	defer unlock()
+
+	legacyPath := filepath.Join(sc.basePath, string(legacyRef))
+	bytes, err := sc.fsOps.ReadFile(legacyPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, origErr
+		}
+		return nil, fmt.Errorf("failed to read cache data in the path(%q): %v", legacyPath, err)
+	}
+	if !isLegacyStateOf(bytes, legacyRef, network, cid, ifname) {
+		return nil, origErr
+	}
+	path := filepath.Join(sc.basePath, string(ref))
+	if err = sc.fsOps.Rename(legacyPath, path); err != nil {
+		return nil, fmt.Errorf("failed to migrate cache data in the path(%q): %v", legacyPath, err)
+	}
+	_ = sc.locker.RemoveRefLock(legacyRef)
+	return bytes, nil
+}
+
+// Check state cached under legacy reference belongs to <networkName, containerID, interfaceName>, by the attachment
+// recorded in it since state version 1.1, or for older states, by the legacy reference if it cannot be split otherwise
+func isLegacyStateOf(data []byte, legacyRef StateRef, network, cid, ifname string) bool {
+	attachment := struct {
+		Network     string `json:"network"`
+		ContainerID string `json:"containerID"`
+		IfName      string `json:"ifName"`
+	}{}
+	if err := json.Unmarshal(data, &attachment); err == nil && attachment.Network != "" {
+		return attachment.Network == network && attachment.ContainerID == cid && attachment.IfName == ifname
+	}
+	return strings.Count(string(legacyRef), legacyStateRefSeparator) == stateRefComponents-1
+}
+
+// Move corrupted state out of the way so it is not loaded again, keeping it for inspection
+func (sc *FsStateCache) quarantine(ref StateRef) error {
+	path := filepath.Join(sc.basePath, string(ref))
+	qDir := filepath.Join(sc.basePath, quarantineDir)
+	qPath := filepath.Join(qDir, string(ref))
+	if err := sc.fsOps.MkdirAll(qDir, dirPerms); err != nil {
+		return fmt.Errorf("%w in the path(%q), failed to create quarantine directory(%q): %v",
+			ErrCorruptedState, path, qDir, err)
+	}
+	if err := sc.fsOps.Rename(path, qPath); err != nil {
+		return fmt.Errorf("%w in the path(%q), failed to quarantine it: %v", ErrCorruptedState, path, err)
+	}
+	return fmt.Errorf("%w in the path(%q), moved to %q", ErrCorruptedState, path, qPath)
+}
+
 func (sc *FsStateCache) Delete(ref StateRef) error {
+	if err := ValidateStateRef(ref); err != nil {
+		return err
+	}
 	sRef := string(ref)
 	path := filepath.Join(sc.basePath, sRef)
 	if err := sc.fsOps.Remove(path); err != nil {
@@ -101,3 +250,107 @@ func (sc *FsStateCache) Delete(ref StateRef) error {
 	}
 	return nil
 }
+
+func (sc *FsStateCache) List() ([]StateRef, error) {
+	entries, err := sc.fsOps.ReadDir(sc.basePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return []StateRef{}, nil
+		}
+		return nil, fmt.Errorf("failed to read cache directory(%q): %v", sc.basePath, err)
+	}
+	refs := make([]StateRef, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), hiddenFilePrefix) {
+			continue
+		}
+		refs = append(refs, StateRef(entry.Name()))
+	}
+	return refs, nil
+}
+
+func (sc *FsStateCache) ListByNetwork(network string) ([]StateRef, error) {
+	return sc.findRdmaNetStates(func(state *types.RdmaNetState) bool {
+		return state.Network == network
+	}, func(refNetwork, _ string) bool {
+		return refNetwork == network
+	})
+}
+
+func (sc *FsStateCache) ListByContainerID(cid string) ([]StateRef, error) {
+	return sc.findRdmaNetStates(func(state *types.RdmaNetState) bool {
+		return state.ContainerID == cid
+	}, func(_, refCid string) bool {
+		return refCid == cid
+	})
+}
+
+func (sc *FsStateCache) ListByDeviceID(deviceID string) ([]StateRef, error) {
+	return sc.findRdmaNetStates(func(state *types.RdmaNetState) bool {
+		return deviceID != "" && state.DeviceID == deviceID
+	}, nil)
+}
+
+func (sc *FsStateCache) ListByRdmaDev(rdmaDev string) ([]StateRef, error) {
+	return sc.findRdmaNetStates(func(state *types.RdmaNetState) bool {
+		return state.HasRdmaDev(rdmaDev)
+	}, nil)
+}
+
+// Find references of RDMA network states in cache for which matchState returns true.
+// If matchRef is provided, it is used instead for references which can be decoded, avoiding loading their state.
+// States which fail to load, e.g corrupted or deleted concurrently, are skipped
+func (sc *FsStateCache) findRdmaNetStates(matchState func(state *types.RdmaNetState) bool,
+	matchRef func(network, cid string) bool) ([]StateRef, error) {
+	refs, err := sc.List()
+	if err != nil {
+		return nil, err
+	}
+	found := make([]StateRef, 0, len(refs))
+	for _, ref := range refs {
+		if matchRef != nil {
+			if network, cid, _, err := decodeStateRef(ref); err == nil {
+				if matchRef(network, cid) {
+					found = append(found, ref)
+				}
+				continue
+			}
+		}
+		state := types.RdmaNetState{}
+		if err := sc.Load(ref, &state); err != nil {
+			continue
+		}
+		if matchState(&state) {
+			found = append(found, ref)
+		}
+	}
+	return found, nil
+}
+
+func (sc *FsStateCache) EnsureWritable() error {
+	if err := sc.fsOps.MkdirAll(sc.basePath, dirPerms); err != nil {
+		return fmt.Errorf("failed to create data cache directory(%q): %v", sc.basePath, err)
+	}
+	path := filepath.Join(sc.basePath, writeProbeFile)
+	if err := sc.fsOps.WriteFile(path, []byte{}, filePerms); err != nil {
+		return fmt.Errorf("data cache directory(%q) is not writable: %v", sc.basePath, err)
+	}
+	if err := sc.fsOps.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove file %q: %v", path, err)
+	}
+	return nil
+}
+
+func (sc *FsStateCache) EnsurePersistent() error {
+	if err := sc.fsOps.MkdirAll(sc.basePath, dirPerms); err != nil {
+		return fmt.Errorf("failed to create data cache directory(%q): %v", sc.basePath, err)
+	}
+	fsType, err := sc.fsOps.FsType(sc.basePath)
+	if err != nil {
+		return fmt.Errorf("failed to get file system type of data cache directory(%q): %v", sc.basePath, err)
+	}
+	if fsType == tmpfsMagic || fsType == ramfsMagic {
+		return fmt.Errorf("%w, directory(%q) file system type 0x%x", ErrNotPersistent, sc.basePath, fsType)
+	}
+	return nil
+}
@@ -17,10 +17,15 @@
 package cache
 
 import (
+	"errors"
+	"os"
 	"path"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+
+	"github.com/k8snetworkplumbingwg/rdma-cni/pkg/types"
 )
 
 type myTestState struct {
@@ -33,18 +38,56 @@ var _ = Describe("Cache - Simple marshall-able state-object cache", func() {
 	var fs FileSystemOps
 	JustBeforeEach(func() {
 		fs = newFakeFileSystemOps()
-		stateCache = &FsStateCache{basePath: CacheDir, fsOps: fs}
+		stateCache = &FsStateCache{basePath: CacheDir, fsOps: fs,
+			locker: &FileLocker{lockDir: GinkgoT().TempDir(), timeout: DefaultLockTimeout}}
 	})
 
 	Describe("Get State reference", func() {
 		Context("Basic call", func() {
-			It("Should return <network>-<cid>-<ifname>", func() {
+			It("Should return <network>:<cid>:<ifname>", func() {
 				Expect(stateCache.GetStateRef("myNet", "containerUniqueIdentifier", "net1")).To(
-					BeEquivalentTo("myNet-containerUniqueIdentifier-net1"))
+					BeEquivalentTo("myNet:containerUniqueIdentifier:net1"))
+			})
+		})
+		Context("Components containing the legacy separator", func() {
+			It("Should return distinct references", func() {
+				Expect(stateCache.GetStateRef("a-b", "c", "net1")).ToNot(
+					Equal(stateCache.GetStateRef("a", "b-c", "net1")))
+			})
+		})
+		Context("Components containing the separator or path elements", func() {
+			It("Should escape them", func() {
+				Expect(stateCache.GetStateRef("a:b", "c", "net1")).To(BeEquivalentTo("a%3Ab:c:net1"))
+				Expect(stateCache.GetStateRef("..", "../../etc", "passwd")).To(
+					BeEquivalentTo("%2E.:%2E.%2F..%2Fetc:passwd"))
+			})
+		})
+		Context("Decode reference", func() {
+			It("Should return the original components", func() {
+				network, cid, ifname, err := decodeStateRef(stateCache.GetStateRef("my/net", "c:id", ".net1"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect([]string{network, cid, ifname}).To(Equal([]string{"my/net", "c:id", ".net1"}))
 			})
 		})
 	})
 
+	Describe("Validate State reference", func() {
+		It("Should accept references returned by GetStateRef", func() {
+			Expect(ValidateStateRef(stateCache.GetStateRef("..", "/", ".lock"))).To(Succeed())
+		})
+		It("Should reject references which are not plain file names in cache directory", func() {
+			for _, ref := range []StateRef{"", ".", "..", "../state", "a/b", ".tmp-123", ".corrupted", "a\x00b"} {
+				Expect(errors.Is(ValidateStateRef(ref), ErrInvalidStateRef)).To(BeTrue(), "ref %q", ref)
+			}
+		})
+		It("Should fail cache operations with invalid references", func() {
+			var loadedState myTestState
+			Expect(stateCache.Save("../state", &myTestState{})).ToNot(Succeed())
+			Expect(stateCache.Load("../state", &loadedState)).ToNot(Succeed())
+			Expect(stateCache.Delete("../state")).ToNot(Succeed())
+		})
+	})
+
 	Describe("Save and Load State", func() {
 		var sRef StateRef
 		JustBeforeEach(func() {
@@ -62,6 +105,99 @@ var _ = Describe("Cache - Simple marshall-able state-object cache", func() {
 				Expect(loadedState).Should(Equal(savedState))
 			})
 		})
+		Context("Save over an existing state", func() {
+			It("Should replace the state and leave no temporary files behind", func() {
+				var loadedState myTestState
+				Expect(stateCache.Save(sRef, &myTestState{FirstState: "first"})).Should(Succeed())
+				Expect(stateCache.Save(sRef, &myTestState{FirstState: "second"})).Should(Succeed())
+				Expect(stateCache.Load(sRef, &loadedState)).Should(Succeed())
+				Expect(loadedState.FirstState).To(Equal("second"))
+				entries, err := fs.ReadDir(CacheDir)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(entries).To(HaveLen(1))
+			})
+		})
+		Context("Save and Load on the OS file system", func() {
+			It("Should save/load the state", func() {
+				osCache := &FsStateCache{basePath: GinkgoT().TempDir(), fsOps: newFsOps()}
+				savedState := myTestState{FirstState: "first", SecondState: 42}
+				var loadedState myTestState
+				Expect(osCache.Save(sRef, &savedState)).Should(Succeed())
+				Expect(osCache.Load(sRef, &loadedState)).Should(Succeed())
+				Expect(loadedState).Should(Equal(savedState))
+				Expect(osCache.List()).To(Equal([]StateRef{sRef}))
+			})
+		})
+		Context("Load corrupted state", func() {
+			It("Should fail and quarantine the state", func() {
+				var loadedState myTestState
+				Expect(fs.MkdirAll(CacheDir, dirPerms)).To(Succeed())
+				Expect(fs.WriteFile(path.Join(CacheDir, string(sRef)), []byte(`{"firstSt`), filePerms)).To(Succeed())
+				err := stateCache.Load(sRef, &loadedState)
+				Expect(errors.Is(err, ErrCorruptedState)).To(BeTrue())
+				_, err = fs.Stat(path.Join(CacheDir, string(sRef)))
+				Expect(err).To(HaveOccurred())
+				_, err = fs.Stat(path.Join(CacheDir, quarantineDir, string(sRef)))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(stateCache.List()).To(BeEmpty())
+			})
+		})
+		Context("Load state saved with legacy reference", func() {
+			It("Should load the state and migrate it to the reference", func() {
+				var loadedState myTestState
+				legacyPath := path.Join(CacheDir, "mynet-cid-net1")
+				Expect(fs.MkdirAll(CacheDir, dirPerms)).To(Succeed())
+				Expect(fs.WriteFile(legacyPath, []byte(`{"firstState":"first"}`), filePerms)).To(Succeed())
+				Expect(stateCache.Load(sRef, &loadedState)).Should(Succeed())
+				Expect(loadedState.FirstState).To(Equal("first"))
+				_, err := fs.Stat(legacyPath)
+				Expect(err).To(HaveOccurred())
+				Expect(stateCache.List()).To(Equal([]StateRef{sRef}))
+			})
+		})
+		Context("Load state saved with ambiguous legacy reference", func() {
+			var ambiguousRef StateRef
+			var legacyPath string
+			JustBeforeEach(func() {
+				ambiguousRef = stateCache.GetStateRef("my-net", "cid", "net1")
+				legacyPath = path.Join(CacheDir, "my-net-cid-net1")
+				Expect(fs.MkdirAll(CacheDir, dirPerms)).To(Succeed())
+			})
+			It("Should migrate the state if it records the same attachment", func() {
+				var loadedState types.RdmaNetState
+				Expect(fs.WriteFile(legacyPath, []byte(`{"version":"1.1","network":"my-net","containerID":"cid",`+
+					`"ifName":"net1"}`), filePerms)).To(Succeed())
+				Expect(stateCache.Load(ambiguousRef, &loadedState)).Should(Succeed())
+				Expect(loadedState.ContainerID).To(Equal("cid"))
+				Expect(stateCache.List()).To(Equal([]StateRef{ambiguousRef}))
+			})
+			It("Should not migrate the state if it records another attachment", func() {
+				var loadedState types.RdmaNetState
+				Expect(fs.WriteFile(legacyPath, []byte(`{"version":"1.1","network":"my","containerID":"net-cid",`+
+					`"ifName":"net1"}`), filePerms)).To(Succeed())
+				err := stateCache.Load(ambiguousRef, &loadedState)
+				Expect(errors.Is(err, os.ErrNotExist)).To(BeTrue())
+				Expect(stateCache.List()).To(Equal([]StateRef{"my-net-cid-net1"}))
+			})
+			It("Should not migrate the state if it does not record the attachment", func() {
+				var loadedState types.RdmaNetState
+				Expect(fs.WriteFile(legacyPath, []byte(`{"version":"1.0"}`), filePerms)).To(Succeed())
+				err := stateCache.Load(ambiguousRef, &loadedState)
+				Expect(errors.Is(err, os.ErrNotExist)).To(BeTrue())
+				Expect(stateCache.List()).To(Equal([]StateRef{"my-net-cid-net1"}))
+			})
+		})
+		Context("Load state listed with legacy reference", func() {
+			It("Should load the state", func() {
+				var loadedState myTestState
+				Expect(fs.MkdirAll(CacheDir, dirPerms)).To(Succeed())
+				Expect(fs.WriteFile(path.Join(CacheDir, "mynet-cid-net1"), []byte(`{"firstState":"first"}`),
+					filePerms)).To(Succeed())
+				Expect(stateCache.List()).To(Equal([]StateRef{"mynet-cid-net1"}))
+				Expect(stateCache.Load("mynet-cid-net1", &loadedState)).Should(Succeed())
+				Expect(loadedState.FirstState).To(Equal("first"))
+			})
+		})
 		Context("Load non-existent state", func() {
 			It("Should fail", func() {
 				var loadedState myTestState
@@ -70,6 +206,135 @@ var _ = Describe("Cache - Simple marshall-able state-object cache", func() {
 		})
 	})
 
+	Describe("List States", func() {
+		Context("Empty cache", func() {
+			It("Should return an empty list", func() {
+				refs, err := stateCache.List()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(refs).To(BeEmpty())
+			})
+		})
+		Context("Saved states", func() {
+			It("Should return references of all saved states", func() {
+				savedState := myTestState{FirstState: "first", SecondState: 42}
+				sRef := stateCache.GetStateRef("mynet", "cid", "net1")
+				altRef := stateCache.GetStateRef("alt-mynet", "cid", "net1")
+				Expect(stateCache.Save(sRef, &savedState)).Should(Succeed())
+				Expect(stateCache.Save(altRef, &savedState)).Should(Succeed())
+				refs, err := stateCache.List()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(refs).To(ConsistOf(sRef, altRef))
+			})
+		})
+	})
+
+	Describe("List RDMA network states by attributes", func() {
+		var sRef, altRef, legacyRef StateRef
+		JustBeforeEach(func() {
+			sRef = stateCache.GetStateRef("mynet", "cid", "net1")
+			altRef = stateCache.GetStateRef("alt-mynet", "cid", "net2")
+			legacyRef = "mynet-alt-cid-net1"
+			state := types.NewRdmaNetState()
+			state.DeviceID = "0000:03:00.2"
+			state.SetRdmaDevs([]types.RdmaDevState{{SandboxRdmaDevName: "mlx5_3"}, {SandboxRdmaDevName: "mlx5_4"}})
+			Expect(stateCache.Save(sRef, &state)).To(Succeed())
+			state.DeviceID = "0000:03:00.3"
+			state.SetRdmaDevs([]types.RdmaDevState{{SandboxRdmaDevName: "mlx5_5"}})
+			Expect(stateCache.Save(altRef, &state)).To(Succeed())
+			state.Network = "mynet"
+			state.ContainerID = "alt-cid"
+			state.SetRdmaDevs([]types.RdmaDevState{{SandboxRdmaDevName: "mlx5_6"}})
+			Expect(stateCache.Save(legacyRef, &state)).To(Succeed())
+		})
+
+		It("Should list states by network, including states with legacy references", func() {
+			Expect(stateCache.ListByNetwork("mynet")).To(ConsistOf(sRef, legacyRef))
+			Expect(stateCache.ListByNetwork("alt-mynet")).To(ConsistOf(altRef))
+			Expect(stateCache.ListByNetwork("other")).To(BeEmpty())
+		})
+		It("Should list states by container ID", func() {
+			Expect(stateCache.ListByContainerID("cid")).To(ConsistOf(sRef, altRef))
+			Expect(stateCache.ListByContainerID("alt-cid")).To(ConsistOf(legacyRef))
+		})
+		It("Should list states by device ID", func() {
+			Expect(stateCache.ListByDeviceID("0000:03:00.2")).To(ConsistOf(sRef))
+			Expect(stateCache.ListByDeviceID("0000:03:00.3")).To(ConsistOf(altRef, legacyRef))
+			Expect(stateCache.ListByDeviceID("")).To(BeEmpty())
+		})
+		It("Should list states by RDMA device", func() {
+			Expect(stateCache.ListByRdmaDev("mlx5_4")).To(ConsistOf(sRef))
+			Expect(stateCache.ListByRdmaDev("mlx5_6")).To(ConsistOf(legacyRef))
+			Expect(stateCache.ListByRdmaDev("mlx5_0")).To(BeEmpty())
+		})
+		It("Should skip corrupted states", func() {
+			Expect(fs.WriteFile(path.Join(CacheDir, "corrupted"), []byte(`{"devi`), filePerms)).To(Succeed())
+			Expect(stateCache.ListByDeviceID("0000:03:00.2")).To(ConsistOf(sRef))
+		})
+	})
+
+	Describe("Ensure cache is writable", func() {
+		Context("Writable cache", func() {
+			It("Should succeed and leave no state behind", func() {
+				Expect(stateCache.EnsureWritable()).To(Succeed())
+				refs, err := stateCache.List()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(refs).To(BeEmpty())
+			})
+		})
+		Context("Read only cache", func() {
+			It("Should fail", func() {
+				roCache := &FsStateCache{basePath: CacheDir, fsOps: &fakeFileSystemOps{
+					fakefs: afero.Afero{Fs: afero.NewReadOnlyFs(afero.NewMemMapFs())}}}
+				Expect(roCache.EnsureWritable()).ToNot(Succeed())
+			})
+		})
+	})
+
+	Describe("Ensure cache is persistent", func() {
+		Context("Cache on a persistent file system", func() {
+			It("Should succeed", func() {
+				Expect(stateCache.EnsurePersistent()).To(Succeed())
+			})
+		})
+		Context("Cache on tmpfs", func() {
+			It("Should fail", func() {
+				fs.(*fakeFileSystemOps).fsType = tmpfsMagic
+				Expect(errors.Is(stateCache.EnsurePersistent(), ErrNotPersistent)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Create state cache", func() {
+		Context("State directory is provided", func() {
+			It("Should use it", func() {
+				GinkgoT().Setenv(StateDirEnv, "/var/lib/rdma-cni-env")
+				Expect(NewStateCache("/var/lib/rdma-cni").(*FsStateCache).basePath).To(Equal("/var/lib/rdma-cni"))
+			})
+		})
+		Context("State directory is not provided", func() {
+			It("Should use the state directory in the environment", func() {
+				GinkgoT().Setenv(StateDirEnv, "/var/lib/rdma-cni-env")
+				Expect(NewStateCache("").(*FsStateCache).basePath).To(Equal("/var/lib/rdma-cni-env"))
+			})
+			It("Should default to CacheDir", func() {
+				GinkgoT().Setenv(StateDirEnv, "")
+				Expect(NewStateCache("").(*FsStateCache).basePath).To(Equal(CacheDir))
+			})
+		})
+		Context("Cache on the OS file system", func() {
+			It("Should save/load the state in the state directory", func() {
+				stateDir := GinkgoT().TempDir()
+				osCache := NewStateCache(stateDir)
+				sRef := osCache.GetStateRef("mynet", "cid", "net1")
+				Expect(osCache.Save(sRef, &myTestState{FirstState: "first"})).To(Succeed())
+				_, err := os.Stat(path.Join(stateDir, string(sRef)))
+				Expect(err).ToNot(HaveOccurred())
+				_, err = osCache.(*FsStateCache).fsOps.FsType(stateDir)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+	})
+
 	Describe("Delete State", func() {
 		var sRef StateRef
 		JustBeforeEach(func() {
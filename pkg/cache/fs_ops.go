@@ -17,7 +17,10 @@
 package cache
 
 import (
+	"io"
+	"io/fs"
 	"os"
+	"syscall"
 
 	"github.com/spf13/afero"
 )
@@ -26,6 +29,17 @@ func newFsOps() FileSystemOps {
 	return &stdFileSystemOps{}
 }
 
+// File created by FileSystemOps.CreateTemp(...)
+type File interface {
+	io.Writer
+	// Equivalent to os.File.Name()
+	Name() string
+	// Equivalent to os.File.Sync()
+	Sync() error
+	// Equivalent to os.File.Close()
+	Close() error
+}
+
 // interface consolidating all file system operations
 type FileSystemOps interface {
 	// Eqivalent to os.ReadFile(...)
@@ -38,6 +52,16 @@ type FileSystemOps interface {
 	Remove(name string) error
 	// Equvalent to os.Stat(...)
 	Stat(name string) (os.FileInfo, error)
+	// Equivalent to os.ReadDir(...)
+	ReadDir(name string) ([]os.DirEntry, error)
+	// Equivalent to os.CreateTemp(...)
+	CreateTemp(dir, pattern string) (File, error)
+	// Equivalent to os.Rename(...)
+	Rename(oldpath, newpath string) error
+	// Flush directory entries of the given directory to stable storage
+	SyncDir(dir string) error
+	// Get type (magic number) of the file system the given path is on, as reported by statfs(2)
+	FsType(path string) (uint32, error)
 }
 
 type stdFileSystemOps struct{}
@@ -62,13 +86,48 @@ func (sfs *stdFileSystemOps) Stat(name string) (os.FileInfo, error) {
 	return os.Stat(name)
 }
 
+func (sfs *stdFileSystemOps) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (sfs *stdFileSystemOps) CreateTemp(dir, pattern string) (File, error) {
+	return os.CreateTemp(dir, pattern)
+}
+
+func (sfs *stdFileSystemOps) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (sfs *stdFileSystemOps) SyncDir(dir string) error {
+	d, err := os.Open(dir) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	if err = d.Sync(); err != nil {
+		_ = d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+func (sfs *stdFileSystemOps) FsType(path string) (uint32, error) {
+	st := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	// Type is of a different integer type on different architectures, magic numbers fit in 32 bits
+	return uint32(st.Type), nil //nolint:gosec
+}
+
 // Fake fileSystemOps used for Unit testing
 func newFakeFileSystemOps() FileSystemOps {
-	return &fakeFileSystemOps{fakefs: afero.Afero{Fs: afero.NewMemMapFs()}}
+	return &fakeFileSystemOps{fakefs: afero.Afero{Fs: afero.NewMemMapFs()}, fsType: ext4Magic}
 }
 
 type fakeFileSystemOps struct {
 	fakefs afero.Afero
+	// File system type reported for all paths
+	fsType uint32
 }
 
 func (ffs *fakeFileSystemOps) ReadFile(filename string) ([]byte, error) {
@@ -90,3 +149,35 @@ func (ffs *fakeFileSystemOps) Remove(name string) error {
 func (ffs *fakeFileSystemOps) Stat(name string) (os.FileInfo, error) {
 	return ffs.fakefs.Stat(name)
 }
+
+func (ffs *fakeFileSystemOps) ReadDir(name string) ([]os.DirEntry, error) {
+	infos, err := ffs.fakefs.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]os.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, nil
+}
+
+func (ffs *fakeFileSystemOps) CreateTemp(dir, pattern string) (File, error) {
+	return ffs.fakefs.TempFile(dir, pattern)
+}
+
+func (ffs *fakeFileSystemOps) Rename(oldpath, newpath string) error {
+	return ffs.fakefs.Rename(oldpath, newpath)
+}
+
+func (ffs *fakeFileSystemOps) SyncDir(dir string) error {
+	_, err := ffs.fakefs.Stat(dir)
+	return err
+}
+
+func (ffs *fakeFileSystemOps) FsType(path string) (uint32, error) {
+	if _, err := ffs.fakefs.Stat(path); err != nil {
+		return 0, err
+	}
+	return ffs.fsType, nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	lockFileSuffix = ".lock"
+	nodeLockFile   = hiddenFilePrefix + "node" + lockFileSuffix
+	// Interval between attempts to acquire a lock
+	lockPollInterval = 50 * time.Millisecond
+	// DefaultLockTimeout is used by default as the max time to wait for a lock
+	DefaultLockTimeout = 30 * time.Second
+)
+
+var (
+	// LockDir is used by default for lock files
+	LockDir = "/var/run/rdma-cni/locks"
+	// ErrLockTimeout is returned when a lock could not be acquired in time
+	ErrLockTimeout = errors.New("timed out waiting for lock")
+)
+
+// Release an acquired lock
+type Unlock func()
+
+// Locker serializes operations of concurrent CNI invocations, across processes
+type Locker interface {
+	// Lock state reference, serializing operations on the same <networkName, containerID, interfaceName>
+	LockRef(ref StateRef) (Unlock, error)
+	// Lock node, serializing operations on RDMA devices of concurrent CNI invocations e.g moving them between
+	// namespaces or setting GUID of their VF, so each invocation sees RDMA devices of the others in a settled state
+	LockNode() (Unlock, error)
+	// Remove lock file of state reference once its cache entry is deleted, must be called while holding its lock
+	RemoveRefLock(ref StateRef) error
+}
+
+// Create a new Locker based on flock(2) of files in LockDir
+func NewLocker() Locker {
+	return &FileLocker{lockDir: LockDir, timeout: DefaultLockTimeout}
+}
+
+type FileLocker struct {
+	lockDir string
+	timeout time.Duration
+}
+
+func (fl *FileLocker) LockRef(ref StateRef) (Unlock, error) {
+	if err := ValidateStateRef(ref); err != nil {
+		return nil, err
+	}
+	return fl.lock(string(ref) + lockFileSuffix)
+}
+
+func (fl *FileLocker) LockNode() (Unlock, error) {
+	return fl.lock(nodeLockFile)
+}
+
+func (fl *FileLocker) RemoveRefLock(ref StateRef) error {
+	if err := ValidateStateRef(ref); err != nil {
+		return err
+	}
+	path := filepath.Join(fl.lockDir, string(ref)+lockFileSuffix)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove lock file(%q): %v", path, err)
+	}
+	return nil
+}
+
+// Acquire exclusive lock on the given file in lock directory, waiting up to timeout.
+// Lock file may be removed by its holder, once locked it is verified to still be the file at path, else retried
+func (fl *FileLocker) lock(name string) (Unlock, error) {
+	if err := os.MkdirAll(fl.lockDir, dirPerms); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory(%q): %v", fl.lockDir, err)
+	}
+	path := filepath.Join(fl.lockDir, name)
+	deadline := time.Now().Add(fl.timeout)
+	for {
+		f, err := fl.flock(path, deadline)
+		if err != nil {
+			return nil, err
+		}
+		if fl.isLockFile(f, path) {
+			fd := int(f.Fd()) //nolint:gosec
+			return func() {
+				_ = syscall.Flock(fd, syscall.LOCK_UN)
+				_ = f.Close()
+			}, nil
+		}
+		// Lock file was removed by previous holder while waiting on it
+		_ = f.Close()
+	}
+}
+
+// Open file at path and acquire exclusive lock on it, waiting up to deadline
+func (fl *FileLocker) flock(path string, deadline time.Time) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, filePerms) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file(%q): %v", path, err)
+	}
+
+	fd := int(f.Fd()) //nolint:gosec
+	for {
+		err = syscall.Flock(fd, syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			_ = f.Close()
+			return nil, fmt.Errorf("failed to lock file(%q): %v", path, err)
+		}
+		if time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("%w on file(%q) after %v", ErrLockTimeout, path, fl.timeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Check locked file is still the file at path
+func (fl *FileLocker) isLockFile(f *os.File, path string) bool {
+	locked, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(locked, current)
+}
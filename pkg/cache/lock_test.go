@@ -0,0 +1,134 @@
+// Copyright 2025 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Locker - flock based locks", func() {
+	var (
+		locker  Locker
+		lockDir string
+	)
+	JustBeforeEach(func() {
+		lockDir = GinkgoT().TempDir()
+		locker = &FileLocker{lockDir: lockDir, timeout: 200 * time.Millisecond}
+	})
+
+	Describe("Lock state reference", func() {
+		Context("Lock is free", func() {
+			It("Should acquire the lock", func() {
+				unlock, err := locker.LockRef("mynet:cid:net1")
+				Expect(err).ToNot(HaveOccurred())
+				unlock()
+			})
+		})
+		Context("Lock is held", func() {
+			It("Should time out", func() {
+				unlock, err := locker.LockRef("mynet:cid:net1")
+				Expect(err).ToNot(HaveOccurred())
+				defer unlock()
+				_, err = locker.LockRef("mynet:cid:net1")
+				Expect(errors.Is(err, ErrLockTimeout)).To(BeTrue())
+			})
+			It("Should acquire the lock once released", func() {
+				unlock, err := locker.LockRef("mynet:cid:net1")
+				Expect(err).ToNot(HaveOccurred())
+				time.AfterFunc(50*time.Millisecond, unlock)
+				unlock, err = locker.LockRef("mynet:cid:net1")
+				Expect(err).ToNot(HaveOccurred())
+				unlock()
+			})
+		})
+		Context("Invalid state reference", func() {
+			It("Should fail", func() {
+				_, err := locker.LockRef("../mynet:cid:net1")
+				Expect(errors.Is(err, ErrInvalidStateRef)).To(BeTrue())
+			})
+		})
+		Context("Lock of another state reference is held", func() {
+			It("Should acquire the lock", func() {
+				unlock, err := locker.LockRef("mynet:cid:net1")
+				Expect(err).ToNot(HaveOccurred())
+				defer unlock()
+				unlock, err = locker.LockRef("mynet:cid:net2")
+				Expect(err).ToNot(HaveOccurred())
+				unlock()
+			})
+		})
+	})
+
+	Describe("Remove lock file of state reference", func() {
+		Context("Lock is held", func() {
+			It("Should remove the lock file", func() {
+				unlock, err := locker.LockRef("mynet:cid:net1")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(locker.RemoveRefLock("mynet:cid:net1")).To(Succeed())
+				unlock()
+				_, err = os.Stat(filepath.Join(lockDir, "mynet:cid:net1"+lockFileSuffix))
+				Expect(errors.Is(err, os.ErrNotExist)).To(BeTrue())
+			})
+			It("Should let a waiting process lock the recreated lock file", func() {
+				unlock, err := locker.LockRef("mynet:cid:net1")
+				Expect(err).ToNot(HaveOccurred())
+				time.AfterFunc(50*time.Millisecond, func() {
+					defer GinkgoRecover()
+					Expect(locker.RemoveRefLock("mynet:cid:net1")).To(Succeed())
+					unlock()
+				})
+				unlock, err = locker.LockRef("mynet:cid:net1")
+				Expect(err).ToNot(HaveOccurred())
+				defer unlock()
+				Expect(filepath.Join(lockDir, "mynet:cid:net1"+lockFileSuffix)).To(BeAnExistingFile())
+				_, err = locker.LockRef("mynet:cid:net1")
+				Expect(errors.Is(err, ErrLockTimeout)).To(BeTrue())
+			})
+		})
+		Context("Lock file does not exist", func() {
+			It("Should succeed", func() {
+				Expect(locker.RemoveRefLock("mynet:cid:net1")).To(Succeed())
+			})
+		})
+		Context("Invalid state reference", func() {
+			It("Should fail", func() {
+				Expect(errors.Is(locker.RemoveRefLock("../mynet:cid:net1"), ErrInvalidStateRef)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Lock node", func() {
+		Context("Lock is held", func() {
+			It("Should time out, not affecting state reference locks", func() {
+				unlock, err := locker.LockNode()
+				Expect(err).ToNot(HaveOccurred())
+				defer unlock()
+				_, err = locker.LockNode()
+				Expect(errors.Is(err, ErrLockTimeout)).To(BeTrue())
+				unlockRef, err := locker.LockRef("mynet:cid:net1")
+				Expect(err).ToNot(HaveOccurred())
+				unlockRef()
+			})
+		})
+	})
+})
@@ -87,6 +87,94 @@ func (_c *MockStateCache_Delete_Call) RunAndReturn(run func(ref cache.StateRef)
 	return _c
 }
 
+// EnsurePersistent provides a mock function for the type MockStateCache
+func (_mock *MockStateCache) EnsurePersistent() error {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnsurePersistent")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func() error); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockStateCache_EnsurePersistent_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'EnsurePersistent'
+type MockStateCache_EnsurePersistent_Call struct {
+	*mock.Call
+}
+
+// EnsurePersistent is a helper method to define mock.On call
+func (_e *MockStateCache_Expecter) EnsurePersistent() *MockStateCache_EnsurePersistent_Call {
+	return &MockStateCache_EnsurePersistent_Call{Call: _e.mock.On("EnsurePersistent")}
+}
+
+func (_c *MockStateCache_EnsurePersistent_Call) Run(run func()) *MockStateCache_EnsurePersistent_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockStateCache_EnsurePersistent_Call) Return(err error) *MockStateCache_EnsurePersistent_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockStateCache_EnsurePersistent_Call) RunAndReturn(run func() error) *MockStateCache_EnsurePersistent_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// EnsureWritable provides a mock function for the type MockStateCache
+func (_mock *MockStateCache) EnsureWritable() error {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnsureWritable")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func() error); ok {
+		r0 = returnFunc()
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockStateCache_EnsureWritable_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'EnsureWritable'
+type MockStateCache_EnsureWritable_Call struct {
+	*mock.Call
+}
+
+// EnsureWritable is a helper method to define mock.On call
+func (_e *MockStateCache_Expecter) EnsureWritable() *MockStateCache_EnsureWritable_Call {
+	return &MockStateCache_EnsureWritable_Call{Call: _e.mock.On("EnsureWritable")}
+}
+
+func (_c *MockStateCache_EnsureWritable_Call) Run(run func()) *MockStateCache_EnsureWritable_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockStateCache_EnsureWritable_Call) Return(err error) *MockStateCache_EnsureWritable_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockStateCache_EnsureWritable_Call) RunAndReturn(run func() error) *MockStateCache_EnsureWritable_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetStateRef provides a mock function for the type MockStateCache
 func (_mock *MockStateCache) GetStateRef(network string, cid string, ifname string) cache.StateRef {
 	ret := _mock.Called(network, cid, ifname)
@@ -150,6 +238,309 @@ func (_c *MockStateCache_GetStateRef_Call) RunAndReturn(run func(network string,
 	return _c
 }
 
+// List provides a mock function for the type MockStateCache
+func (_mock *MockStateCache) List() ([]cache.StateRef, error) {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for List")
+	}
+
+	var r0 []cache.StateRef
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func() ([]cache.StateRef, error)); ok {
+		return returnFunc()
+	}
+	if returnFunc, ok := ret.Get(0).(func() []cache.StateRef); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]cache.StateRef)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func() error); ok {
+		r1 = returnFunc()
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStateCache_List_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'List'
+type MockStateCache_List_Call struct {
+	*mock.Call
+}
+
+// List is a helper method to define mock.On call
+func (_e *MockStateCache_Expecter) List() *MockStateCache_List_Call {
+	return &MockStateCache_List_Call{Call: _e.mock.On("List")}
+}
+
+func (_c *MockStateCache_List_Call) Run(run func()) *MockStateCache_List_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockStateCache_List_Call) Return(stateRefs []cache.StateRef, err error) *MockStateCache_List_Call {
+	_c.Call.Return(stateRefs, err)
+	return _c
+}
+
+func (_c *MockStateCache_List_Call) RunAndReturn(run func() ([]cache.StateRef, error)) *MockStateCache_List_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListByContainerID provides a mock function for the type MockStateCache
+func (_mock *MockStateCache) ListByContainerID(cid string) ([]cache.StateRef, error) {
+	ret := _mock.Called(cid)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListByContainerID")
+	}
+
+	var r0 []cache.StateRef
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) ([]cache.StateRef, error)); ok {
+		return returnFunc(cid)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) []cache.StateRef); ok {
+		r0 = returnFunc(cid)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]cache.StateRef)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(cid)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStateCache_ListByContainerID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListByContainerID'
+type MockStateCache_ListByContainerID_Call struct {
+	*mock.Call
+}
+
+// ListByContainerID is a helper method to define mock.On call
+//   - cid string
+func (_e *MockStateCache_Expecter) ListByContainerID(cid interface{}) *MockStateCache_ListByContainerID_Call {
+	return &MockStateCache_ListByContainerID_Call{Call: _e.mock.On("ListByContainerID", cid)}
+}
+
+func (_c *MockStateCache_ListByContainerID_Call) Run(run func(cid string)) *MockStateCache_ListByContainerID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStateCache_ListByContainerID_Call) Return(stateRefs []cache.StateRef, err error) *MockStateCache_ListByContainerID_Call {
+	_c.Call.Return(stateRefs, err)
+	return _c
+}
+
+func (_c *MockStateCache_ListByContainerID_Call) RunAndReturn(run func(cid string) ([]cache.StateRef, error)) *MockStateCache_ListByContainerID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListByDeviceID provides a mock function for the type MockStateCache
+func (_mock *MockStateCache) ListByDeviceID(deviceID string) ([]cache.StateRef, error) {
+	ret := _mock.Called(deviceID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListByDeviceID")
+	}
+
+	var r0 []cache.StateRef
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) ([]cache.StateRef, error)); ok {
+		return returnFunc(deviceID)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) []cache.StateRef); ok {
+		r0 = returnFunc(deviceID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]cache.StateRef)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStateCache_ListByDeviceID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListByDeviceID'
+type MockStateCache_ListByDeviceID_Call struct {
+	*mock.Call
+}
+
+// ListByDeviceID is a helper method to define mock.On call
+//   - deviceID string
+func (_e *MockStateCache_Expecter) ListByDeviceID(deviceID interface{}) *MockStateCache_ListByDeviceID_Call {
+	return &MockStateCache_ListByDeviceID_Call{Call: _e.mock.On("ListByDeviceID", deviceID)}
+}
+
+func (_c *MockStateCache_ListByDeviceID_Call) Run(run func(deviceID string)) *MockStateCache_ListByDeviceID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStateCache_ListByDeviceID_Call) Return(stateRefs []cache.StateRef, err error) *MockStateCache_ListByDeviceID_Call {
+	_c.Call.Return(stateRefs, err)
+	return _c
+}
+
+func (_c *MockStateCache_ListByDeviceID_Call) RunAndReturn(run func(deviceID string) ([]cache.StateRef, error)) *MockStateCache_ListByDeviceID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListByNetwork provides a mock function for the type MockStateCache
+func (_mock *MockStateCache) ListByNetwork(network string) ([]cache.StateRef, error) {
+	ret := _mock.Called(network)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListByNetwork")
+	}
+
+	var r0 []cache.StateRef
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) ([]cache.StateRef, error)); ok {
+		return returnFunc(network)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) []cache.StateRef); ok {
+		r0 = returnFunc(network)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]cache.StateRef)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(network)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStateCache_ListByNetwork_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListByNetwork'
+type MockStateCache_ListByNetwork_Call struct {
+	*mock.Call
+}
+
+// ListByNetwork is a helper method to define mock.On call
+//   - network string
+func (_e *MockStateCache_Expecter) ListByNetwork(network interface{}) *MockStateCache_ListByNetwork_Call {
+	return &MockStateCache_ListByNetwork_Call{Call: _e.mock.On("ListByNetwork", network)}
+}
+
+func (_c *MockStateCache_ListByNetwork_Call) Run(run func(network string)) *MockStateCache_ListByNetwork_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStateCache_ListByNetwork_Call) Return(stateRefs []cache.StateRef, err error) *MockStateCache_ListByNetwork_Call {
+	_c.Call.Return(stateRefs, err)
+	return _c
+}
+
+func (_c *MockStateCache_ListByNetwork_Call) RunAndReturn(run func(network string) ([]cache.StateRef, error)) *MockStateCache_ListByNetwork_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListByRdmaDev provides a mock function for the type MockStateCache
+func (_mock *MockStateCache) ListByRdmaDev(rdmaDev string) ([]cache.StateRef, error) {
+	ret := _mock.Called(rdmaDev)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListByRdmaDev")
+	}
+
+	var r0 []cache.StateRef
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) ([]cache.StateRef, error)); ok {
+		return returnFunc(rdmaDev)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) []cache.StateRef); ok {
+		r0 = returnFunc(rdmaDev)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]cache.StateRef)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(rdmaDev)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStateCache_ListByRdmaDev_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListByRdmaDev'
+type MockStateCache_ListByRdmaDev_Call struct {
+	*mock.Call
+}
+
+// ListByRdmaDev is a helper method to define mock.On call
+//   - rdmaDev string
+func (_e *MockStateCache_Expecter) ListByRdmaDev(rdmaDev interface{}) *MockStateCache_ListByRdmaDev_Call {
+	return &MockStateCache_ListByRdmaDev_Call{Call: _e.mock.On("ListByRdmaDev", rdmaDev)}
+}
+
+func (_c *MockStateCache_ListByRdmaDev_Call) Run(run func(rdmaDev string)) *MockStateCache_ListByRdmaDev_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStateCache_ListByRdmaDev_Call) Return(stateRefs []cache.StateRef, err error) *MockStateCache_ListByRdmaDev_Call {
+	_c.Call.Return(stateRefs, err)
+	return _c
+}
+
+func (_c *MockStateCache_ListByRdmaDev_Call) RunAndReturn(run func(rdmaDev string) ([]cache.StateRef, error)) *MockStateCache_ListByRdmaDev_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Load provides a mock function for the type MockStateCache
 func (_mock *MockStateCache) Load(ref cache.StateRef, state interface{}) error {
 	ret := _mock.Called(ref, state)
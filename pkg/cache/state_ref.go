@@ -0,0 +1,98 @@
+// Copyright 2025 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Separator of state reference components, escaped within components
+	stateRefSeparator = ":"
+	// Separator of state reference components prior to escaping, ambiguous when components contain it
+	legacyStateRefSeparator = "-"
+	stateRefComponents      = 3
+)
+
+// ErrInvalidStateRef is returned when a state reference is not a valid cache file name
+var ErrInvalidStateRef = errors.New("invalid state reference")
+
+// Escape state reference component, only characters which are safe in a file name and are not
+// the separator are kept as is, others are percent-encoded
+func escapeStateRefComponent(component string) string {
+	var sb strings.Builder
+	for i := 0; i < len(component); i++ {
+		c := component[i]
+		isSafe := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || (c == '.' && i > 0)
+		if isSafe {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// Encode state reference of <networkName, containerID, interfaceName>
+func encodeStateRef(network, cid, ifname string) StateRef {
+	return StateRef(strings.Join([]string{
+		escapeStateRefComponent(network), escapeStateRefComponent(cid), escapeStateRefComponent(ifname),
+	}, stateRefSeparator))
+}
+
+// Decode state reference to <networkName, containerID, interfaceName>
+func decodeStateRef(ref StateRef) (network, cid, ifname string, err error) {
+	components := strings.Split(string(ref), stateRefSeparator)
+	if len(components) != stateRefComponents {
+		return "", "", "", fmt.Errorf("%w %q, expecting %d components", ErrInvalidStateRef, ref, stateRefComponents)
+	}
+	for i := range components {
+		if components[i], err = url.PathUnescape(components[i]); err != nil {
+			return "", "", "", fmt.Errorf("%w %q. %v", ErrInvalidStateRef, ref, err)
+		}
+	}
+	return components[0], components[1], components[2], nil
+}
+
+// Get state reference of <networkName, containerID, interfaceName> in the format used prior to escaping components,
+// for reading states cached by previous versions
+func legacyStateRef(network, cid, ifname string) (StateRef, error) {
+	legacyRef := StateRef(strings.Join([]string{network, cid, ifname}, legacyStateRefSeparator))
+	if err := ValidateStateRef(legacyRef); err != nil {
+		return "", err
+	}
+	return legacyRef, nil
+}
+
+// ValidateStateRef ensures state reference is a plain file name in cache directory,
+// so it cannot be used to access files outside of it or files used by the cache itself
+func ValidateStateRef(ref StateRef) error {
+	sRef := string(ref)
+	switch {
+	case sRef == "":
+		return fmt.Errorf("%w, empty", ErrInvalidStateRef)
+	case strings.ContainsAny(sRef, "/\x00"):
+		return fmt.Errorf("%w %q, contains path separator or NUL", ErrInvalidStateRef, sRef)
+	case strings.HasPrefix(sRef, hiddenFilePrefix):
+		return fmt.Errorf("%w %q, starts with %q", ErrInvalidStateRef, sRef, hiddenFilePrefix)
+	}
+	return nil
+}
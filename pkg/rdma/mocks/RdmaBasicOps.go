@@ -7,6 +7,7 @@ package mocks
 import (
 	mock "github.com/stretchr/testify/mock"
 	"github.com/vishvananda/netlink"
+	"net"
 )
 
 // NewMockBasicOps creates a new instance of MockBasicOps. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
@@ -36,6 +37,125 @@ func (_m *MockBasicOps) EXPECT() *MockBasicOps_Expecter {
 	return &MockBasicOps_Expecter{mock: &_m.Mock}
 }
 
+// GetPfNetdevAndVfIndex provides a mock function for the type MockBasicOps
+func (_mock *MockBasicOps) GetPfNetdevAndVfIndex(vfPciDev string) (string, int, error) {
+	ret := _mock.Called(vfPciDev)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPfNetdevAndVfIndex")
+	}
+
+	var r0 string
+	var r1 int
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(string) (string, int, error)); ok {
+		return returnFunc(vfPciDev)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) string); ok {
+		r0 = returnFunc(vfPciDev)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) int); ok {
+		r1 = returnFunc(vfPciDev)
+	} else {
+		r1 = ret.Get(1).(int)
+	}
+	if returnFunc, ok := ret.Get(2).(func(string) error); ok {
+		r2 = returnFunc(vfPciDev)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// MockBasicOps_GetPfNetdevAndVfIndex_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetPfNetdevAndVfIndex'
+type MockBasicOps_GetPfNetdevAndVfIndex_Call struct {
+	*mock.Call
+}
+
+// GetPfNetdevAndVfIndex is a helper method to define mock.On call
+//   - vfPciDev string
+func (_e *MockBasicOps_Expecter) GetPfNetdevAndVfIndex(vfPciDev interface{}) *MockBasicOps_GetPfNetdevAndVfIndex_Call {
+	return &MockBasicOps_GetPfNetdevAndVfIndex_Call{Call: _e.mock.On("GetPfNetdevAndVfIndex", vfPciDev)}
+}
+
+func (_c *MockBasicOps_GetPfNetdevAndVfIndex_Call) Run(run func(vfPciDev string)) *MockBasicOps_GetPfNetdevAndVfIndex_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockBasicOps_GetPfNetdevAndVfIndex_Call) Return(s string, n int, err error) *MockBasicOps_GetPfNetdevAndVfIndex_Call {
+	_c.Call.Return(s, n, err)
+	return _c
+}
+
+func (_c *MockBasicOps_GetPfNetdevAndVfIndex_Call) RunAndReturn(run func(vfPciDev string) (string, int, error)) *MockBasicOps_GetPfNetdevAndVfIndex_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetPorts provides a mock function for the type MockBasicOps
+func (_mock *MockBasicOps) GetPorts(rdmaDeviceName string) []string {
+	ret := _mock.Called(rdmaDeviceName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPorts")
+	}
+
+	var r0 []string
+	if returnFunc, ok := ret.Get(0).(func(string) []string); ok {
+		r0 = returnFunc(rdmaDeviceName)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	return r0
+}
+
+// MockBasicOps_GetPorts_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetPorts'
+type MockBasicOps_GetPorts_Call struct {
+	*mock.Call
+}
+
+// GetPorts is a helper method to define mock.On call
+//   - rdmaDeviceName string
+func (_e *MockBasicOps_Expecter) GetPorts(rdmaDeviceName interface{}) *MockBasicOps_GetPorts_Call {
+	return &MockBasicOps_GetPorts_Call{Call: _e.mock.On("GetPorts", rdmaDeviceName)}
+}
+
+func (_c *MockBasicOps_GetPorts_Call) Run(run func(rdmaDeviceName string)) *MockBasicOps_GetPorts_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockBasicOps_GetPorts_Call) Return(strings []string) *MockBasicOps_GetPorts_Call {
+	_c.Call.Return(strings)
+	return _c
+}
+
+func (_c *MockBasicOps_GetPorts_Call) RunAndReturn(run func(rdmaDeviceName string) []string) *MockBasicOps_GetPorts_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetRdmaDevicesForAuxdev provides a mock function for the type MockBasicOps
 func (_mock *MockBasicOps) GetRdmaDevicesForAuxdev(auxDev string) []string {
 	ret := _mock.Called(auxDev)
@@ -142,6 +262,262 @@ func (_c *MockBasicOps_GetRdmaDevicesForPcidev_Call) RunAndReturn(run func(pcide
 	return _c
 }
 
+// LinkByName provides a mock function for the type MockBasicOps
+func (_mock *MockBasicOps) LinkByName(name string) (netlink.Link, error) {
+	ret := _mock.Called(name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LinkByName")
+	}
+
+	var r0 netlink.Link
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) (netlink.Link, error)); ok {
+		return returnFunc(name)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) netlink.Link); ok {
+		r0 = returnFunc(name)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(netlink.Link)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(name)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockBasicOps_LinkByName_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'LinkByName'
+type MockBasicOps_LinkByName_Call struct {
+	*mock.Call
+}
+
+// LinkByName is a helper method to define mock.On call
+//   - name string
+func (_e *MockBasicOps_Expecter) LinkByName(name interface{}) *MockBasicOps_LinkByName_Call {
+	return &MockBasicOps_LinkByName_Call{Call: _e.mock.On("LinkByName", name)}
+}
+
+func (_c *MockBasicOps_LinkByName_Call) Run(run func(name string)) *MockBasicOps_LinkByName_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockBasicOps_LinkByName_Call) Return(link netlink.Link, err error) *MockBasicOps_LinkByName_Call {
+	_c.Call.Return(link, err)
+	return _c
+}
+
+func (_c *MockBasicOps_LinkByName_Call) RunAndReturn(run func(name string) (netlink.Link, error)) *MockBasicOps_LinkByName_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// LinkSetVfNodeGUID provides a mock function for the type MockBasicOps
+func (_mock *MockBasicOps) LinkSetVfNodeGUID(link netlink.Link, vf int, nodeguid net.HardwareAddr) error {
+	ret := _mock.Called(link, vf, nodeguid)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LinkSetVfNodeGUID")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(netlink.Link, int, net.HardwareAddr) error); ok {
+		r0 = returnFunc(link, vf, nodeguid)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockBasicOps_LinkSetVfNodeGUID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'LinkSetVfNodeGUID'
+type MockBasicOps_LinkSetVfNodeGUID_Call struct {
+	*mock.Call
+}
+
+// LinkSetVfNodeGUID is a helper method to define mock.On call
+//   - link netlink.Link
+//   - vf int
+//   - nodeguid net.HardwareAddr
+func (_e *MockBasicOps_Expecter) LinkSetVfNodeGUID(link interface{}, vf interface{}, nodeguid interface{}) *MockBasicOps_LinkSetVfNodeGUID_Call {
+	return &MockBasicOps_LinkSetVfNodeGUID_Call{Call: _e.mock.On("LinkSetVfNodeGUID", link, vf, nodeguid)}
+}
+
+func (_c *MockBasicOps_LinkSetVfNodeGUID_Call) Run(run func(link netlink.Link, vf int, nodeguid net.HardwareAddr)) *MockBasicOps_LinkSetVfNodeGUID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 netlink.Link
+		if args[0] != nil {
+			arg0 = args[0].(netlink.Link)
+		}
+		var arg1 int
+		if args[1] != nil {
+			arg1 = args[1].(int)
+		}
+		var arg2 net.HardwareAddr
+		if args[2] != nil {
+			arg2 = args[2].(net.HardwareAddr)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockBasicOps_LinkSetVfNodeGUID_Call) Return(err error) *MockBasicOps_LinkSetVfNodeGUID_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockBasicOps_LinkSetVfNodeGUID_Call) RunAndReturn(run func(link netlink.Link, vf int, nodeguid net.HardwareAddr) error) *MockBasicOps_LinkSetVfNodeGUID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// LinkSetVfPortGUID provides a mock function for the type MockBasicOps
+func (_mock *MockBasicOps) LinkSetVfPortGUID(link netlink.Link, vf int, portguid net.HardwareAddr) error {
+	ret := _mock.Called(link, vf, portguid)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LinkSetVfPortGUID")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(netlink.Link, int, net.HardwareAddr) error); ok {
+		r0 = returnFunc(link, vf, portguid)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockBasicOps_LinkSetVfPortGUID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'LinkSetVfPortGUID'
+type MockBasicOps_LinkSetVfPortGUID_Call struct {
+	*mock.Call
+}
+
+// LinkSetVfPortGUID is a helper method to define mock.On call
+//   - link netlink.Link
+//   - vf int
+//   - portguid net.HardwareAddr
+func (_e *MockBasicOps_Expecter) LinkSetVfPortGUID(link interface{}, vf interface{}, portguid interface{}) *MockBasicOps_LinkSetVfPortGUID_Call {
+	return &MockBasicOps_LinkSetVfPortGUID_Call{Call: _e.mock.On("LinkSetVfPortGUID", link, vf, portguid)}
+}
+
+func (_c *MockBasicOps_LinkSetVfPortGUID_Call) Run(run func(link netlink.Link, vf int, portguid net.HardwareAddr)) *MockBasicOps_LinkSetVfPortGUID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 netlink.Link
+		if args[0] != nil {
+			arg0 = args[0].(netlink.Link)
+		}
+		var arg1 int
+		if args[1] != nil {
+			arg1 = args[1].(int)
+		}
+		var arg2 net.HardwareAddr
+		if args[2] != nil {
+			arg2 = args[2].(net.HardwareAddr)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockBasicOps_LinkSetVfPortGUID_Call) Return(err error) *MockBasicOps_LinkSetVfPortGUID_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockBasicOps_LinkSetVfPortGUID_Call) RunAndReturn(run func(link netlink.Link, vf int, portguid net.HardwareAddr) error) *MockBasicOps_LinkSetVfPortGUID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListRdmaDevAttrs provides a mock function for the type MockBasicOps
+func (_mock *MockBasicOps) ListRdmaDevAttrs(rdmaDeviceName string, dir string) ([]string, error) {
+	ret := _mock.Called(rdmaDeviceName, dir)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListRdmaDevAttrs")
+	}
+
+	var r0 []string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string, string) ([]string, error)); ok {
+		return returnFunc(rdmaDeviceName, dir)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string, string) []string); ok {
+		r0 = returnFunc(rdmaDeviceName, dir)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = returnFunc(rdmaDeviceName, dir)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockBasicOps_ListRdmaDevAttrs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListRdmaDevAttrs'
+type MockBasicOps_ListRdmaDevAttrs_Call struct {
+	*mock.Call
+}
+
+// ListRdmaDevAttrs is a helper method to define mock.On call
+//   - rdmaDeviceName string
+//   - dir string
+func (_e *MockBasicOps_Expecter) ListRdmaDevAttrs(rdmaDeviceName interface{}, dir interface{}) *MockBasicOps_ListRdmaDevAttrs_Call {
+	return &MockBasicOps_ListRdmaDevAttrs_Call{Call: _e.mock.On("ListRdmaDevAttrs", rdmaDeviceName, dir)}
+}
+
+func (_c *MockBasicOps_ListRdmaDevAttrs_Call) Run(run func(rdmaDeviceName string, dir string)) *MockBasicOps_ListRdmaDevAttrs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockBasicOps_ListRdmaDevAttrs_Call) Return(strings []string, err error) *MockBasicOps_ListRdmaDevAttrs_Call {
+	_c.Call.Return(strings, err)
+	return _c
+}
+
+func (_c *MockBasicOps_ListRdmaDevAttrs_Call) RunAndReturn(run func(rdmaDeviceName string, dir string) ([]string, error)) *MockBasicOps_ListRdmaDevAttrs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // RdmaLinkByName provides a mock function for the type MockBasicOps
 func (_mock *MockBasicOps) RdmaLinkByName(name string) (*netlink.RdmaLink, error) {
 	ret := _mock.Called(name)
@@ -204,6 +580,118 @@ func (_c *MockBasicOps_RdmaLinkByName_Call) RunAndReturn(run func(name string) (
 	return _c
 }
 
+// RdmaLinkList provides a mock function for the type MockBasicOps
+func (_mock *MockBasicOps) RdmaLinkList() ([]*netlink.RdmaLink, error) {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for RdmaLinkList")
+	}
+
+	var r0 []*netlink.RdmaLink
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func() ([]*netlink.RdmaLink, error)); ok {
+		return returnFunc()
+	}
+	if returnFunc, ok := ret.Get(0).(func() []*netlink.RdmaLink); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*netlink.RdmaLink)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func() error); ok {
+		r1 = returnFunc()
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockBasicOps_RdmaLinkList_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RdmaLinkList'
+type MockBasicOps_RdmaLinkList_Call struct {
+	*mock.Call
+}
+
+// RdmaLinkList is a helper method to define mock.On call
+func (_e *MockBasicOps_Expecter) RdmaLinkList() *MockBasicOps_RdmaLinkList_Call {
+	return &MockBasicOps_RdmaLinkList_Call{Call: _e.mock.On("RdmaLinkList")}
+}
+
+func (_c *MockBasicOps_RdmaLinkList_Call) Run(run func()) *MockBasicOps_RdmaLinkList_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockBasicOps_RdmaLinkList_Call) Return(rdmaLinks []*netlink.RdmaLink, err error) *MockBasicOps_RdmaLinkList_Call {
+	_c.Call.Return(rdmaLinks, err)
+	return _c
+}
+
+func (_c *MockBasicOps_RdmaLinkList_Call) RunAndReturn(run func() ([]*netlink.RdmaLink, error)) *MockBasicOps_RdmaLinkList_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RdmaLinkSetName provides a mock function for the type MockBasicOps
+func (_mock *MockBasicOps) RdmaLinkSetName(link *netlink.RdmaLink, name string) error {
+	ret := _mock.Called(link, name)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RdmaLinkSetName")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(*netlink.RdmaLink, string) error); ok {
+		r0 = returnFunc(link, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockBasicOps_RdmaLinkSetName_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RdmaLinkSetName'
+type MockBasicOps_RdmaLinkSetName_Call struct {
+	*mock.Call
+}
+
+// RdmaLinkSetName is a helper method to define mock.On call
+//   - link *netlink.RdmaLink
+//   - name string
+func (_e *MockBasicOps_Expecter) RdmaLinkSetName(link interface{}, name interface{}) *MockBasicOps_RdmaLinkSetName_Call {
+	return &MockBasicOps_RdmaLinkSetName_Call{Call: _e.mock.On("RdmaLinkSetName", link, name)}
+}
+
+func (_c *MockBasicOps_RdmaLinkSetName_Call) Run(run func(link *netlink.RdmaLink, name string)) *MockBasicOps_RdmaLinkSetName_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 *netlink.RdmaLink
+		if args[0] != nil {
+			arg0 = args[0].(*netlink.RdmaLink)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockBasicOps_RdmaLinkSetName_Call) Return(err error) *MockBasicOps_RdmaLinkSetName_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockBasicOps_RdmaLinkSetName_Call) RunAndReturn(run func(link *netlink.RdmaLink, name string) error) *MockBasicOps_RdmaLinkSetName_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // RdmaLinkSetNsFd provides a mock function for the type MockBasicOps
 func (_mock *MockBasicOps) RdmaLinkSetNsFd(link *netlink.RdmaLink, fd uint32) error {
 	ret := _mock.Called(link, fd)
@@ -364,3 +852,69 @@ func (_c *MockBasicOps_RdmaSystemSetNetnsMode_Call) RunAndReturn(run func(newMod
 	_c.Call.Return(run)
 	return _c
 }
+
+// ReadRdmaDevAttr provides a mock function for the type MockBasicOps
+func (_mock *MockBasicOps) ReadRdmaDevAttr(rdmaDeviceName string, attr string) (string, error) {
+	ret := _mock.Called(rdmaDeviceName, attr)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReadRdmaDevAttr")
+	}
+
+	var r0 string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string, string) (string, error)); ok {
+		return returnFunc(rdmaDeviceName, attr)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string, string) string); ok {
+		r0 = returnFunc(rdmaDeviceName, attr)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	if returnFunc, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = returnFunc(rdmaDeviceName, attr)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockBasicOps_ReadRdmaDevAttr_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ReadRdmaDevAttr'
+type MockBasicOps_ReadRdmaDevAttr_Call struct {
+	*mock.Call
+}
+
+// ReadRdmaDevAttr is a helper method to define mock.On call
+//   - rdmaDeviceName string
+//   - attr string
+func (_e *MockBasicOps_Expecter) ReadRdmaDevAttr(rdmaDeviceName interface{}, attr interface{}) *MockBasicOps_ReadRdmaDevAttr_Call {
+	return &MockBasicOps_ReadRdmaDevAttr_Call{Call: _e.mock.On("ReadRdmaDevAttr", rdmaDeviceName, attr)}
+}
+
+func (_c *MockBasicOps_ReadRdmaDevAttr_Call) Run(run func(rdmaDeviceName string, attr string)) *MockBasicOps_ReadRdmaDevAttr_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockBasicOps_ReadRdmaDevAttr_Call) Return(s string, err error) *MockBasicOps_ReadRdmaDevAttr_Call {
+	_c.Call.Return(s, err)
+	return _c
+}
+
+func (_c *MockBasicOps_ReadRdmaDevAttr_Call) RunAndReturn(run func(rdmaDeviceName string, attr string) (string, error)) *MockBasicOps_ReadRdmaDevAttr_Call {
+	_c.Call.Return(run)
+	return _c
+}
@@ -6,7 +6,10 @@ package mocks
 
 import (
 	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/k8snetworkplumbingwg/rdma-cni/pkg/types"
 	mock "github.com/stretchr/testify/mock"
+	"net"
+	"time"
 )
 
 // NewMockManager creates a new instance of MockManager. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
@@ -36,6 +39,364 @@ func (_m *MockManager) EXPECT() *MockManager_Expecter {
 	return &MockManager_Expecter{mock: &_m.Mock}
 }
 
+// GetIPoIBPkey provides a mock function for the type MockManager
+func (_mock *MockManager) GetIPoIBPkey(netdev string) (uint16, error) {
+	ret := _mock.Called(netdev)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetIPoIBPkey")
+	}
+
+	var r0 uint16
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) (uint16, error)); ok {
+		return returnFunc(netdev)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) uint16); ok {
+		r0 = returnFunc(netdev)
+	} else {
+		r0 = ret.Get(0).(uint16)
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(netdev)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockManager_GetIPoIBPkey_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetIPoIBPkey'
+type MockManager_GetIPoIBPkey_Call struct {
+	*mock.Call
+}
+
+// GetIPoIBPkey is a helper method to define mock.On call
+//   - netdev string
+func (_e *MockManager_Expecter) GetIPoIBPkey(netdev interface{}) *MockManager_GetIPoIBPkey_Call {
+	return &MockManager_GetIPoIBPkey_Call{Call: _e.mock.On("GetIPoIBPkey", netdev)}
+}
+
+func (_c *MockManager_GetIPoIBPkey_Call) Run(run func(netdev string)) *MockManager_GetIPoIBPkey_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockManager_GetIPoIBPkey_Call) Return(v uint16, err error) *MockManager_GetIPoIBPkey_Call {
+	_c.Call.Return(v, err)
+	return _c
+}
+
+func (_c *MockManager_GetIPoIBPkey_Call) RunAndReturn(run func(netdev string) (uint16, error)) *MockManager_GetIPoIBPkey_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetNetdevParentDev provides a mock function for the type MockManager
+func (_mock *MockManager) GetNetdevParentDev(netdev string) (string, error) {
+	ret := _mock.Called(netdev)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetNetdevParentDev")
+	}
+
+	var r0 string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) (string, error)); ok {
+		return returnFunc(netdev)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) string); ok {
+		r0 = returnFunc(netdev)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(netdev)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockManager_GetNetdevParentDev_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetNetdevParentDev'
+type MockManager_GetNetdevParentDev_Call struct {
+	*mock.Call
+}
+
+// GetNetdevParentDev is a helper method to define mock.On call
+//   - netdev string
+func (_e *MockManager_Expecter) GetNetdevParentDev(netdev interface{}) *MockManager_GetNetdevParentDev_Call {
+	return &MockManager_GetNetdevParentDev_Call{Call: _e.mock.On("GetNetdevParentDev", netdev)}
+}
+
+func (_c *MockManager_GetNetdevParentDev_Call) Run(run func(netdev string)) *MockManager_GetNetdevParentDev_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockManager_GetNetdevParentDev_Call) Return(s string, err error) *MockManager_GetNetdevParentDev_Call {
+	_c.Call.Return(s, err)
+	return _c
+}
+
+func (_c *MockManager_GetNetdevParentDev_Call) RunAndReturn(run func(netdev string) (string, error)) *MockManager_GetNetdevParentDev_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRdmaDevInfo provides a mock function for the type MockManager
+func (_mock *MockManager) GetRdmaDevInfo(rdmaDev string) (*types.RdmaDevInfo, error) {
+	ret := _mock.Called(rdmaDev)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRdmaDevInfo")
+	}
+
+	var r0 *types.RdmaDevInfo
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string) (*types.RdmaDevInfo, error)); ok {
+		return returnFunc(rdmaDev)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string) *types.RdmaDevInfo); ok {
+		r0 = returnFunc(rdmaDev)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*types.RdmaDevInfo)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
+		r1 = returnFunc(rdmaDev)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockManager_GetRdmaDevInfo_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRdmaDevInfo'
+type MockManager_GetRdmaDevInfo_Call struct {
+	*mock.Call
+}
+
+// GetRdmaDevInfo is a helper method to define mock.On call
+//   - rdmaDev string
+func (_e *MockManager_Expecter) GetRdmaDevInfo(rdmaDev interface{}) *MockManager_GetRdmaDevInfo_Call {
+	return &MockManager_GetRdmaDevInfo_Call{Call: _e.mock.On("GetRdmaDevInfo", rdmaDev)}
+}
+
+func (_c *MockManager_GetRdmaDevInfo_Call) Run(run func(rdmaDev string)) *MockManager_GetRdmaDevInfo_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockManager_GetRdmaDevInfo_Call) Return(rdmaDevInfo *types.RdmaDevInfo, err error) *MockManager_GetRdmaDevInfo_Call {
+	_c.Call.Return(rdmaDevInfo, err)
+	return _c
+}
+
+func (_c *MockManager_GetRdmaDevInfo_Call) RunAndReturn(run func(rdmaDev string) (*types.RdmaDevInfo, error)) *MockManager_GetRdmaDevInfo_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRdmaDevPkeys provides a mock function for the type MockManager
+func (_mock *MockManager) GetRdmaDevPkeys(rdmaDev string, port string) ([]uint16, error) {
+	ret := _mock.Called(rdmaDev, port)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRdmaDevPkeys")
+	}
+
+	var r0 []uint16
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(string, string) ([]uint16, error)); ok {
+		return returnFunc(rdmaDev, port)
+	}
+	if returnFunc, ok := ret.Get(0).(func(string, string) []uint16); ok {
+		r0 = returnFunc(rdmaDev, port)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]uint16)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = returnFunc(rdmaDev, port)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockManager_GetRdmaDevPkeys_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRdmaDevPkeys'
+type MockManager_GetRdmaDevPkeys_Call struct {
+	*mock.Call
+}
+
+// GetRdmaDevPkeys is a helper method to define mock.On call
+//   - rdmaDev string
+//   - port string
+func (_e *MockManager_Expecter) GetRdmaDevPkeys(rdmaDev interface{}, port interface{}) *MockManager_GetRdmaDevPkeys_Call {
+	return &MockManager_GetRdmaDevPkeys_Call{Call: _e.mock.On("GetRdmaDevPkeys", rdmaDev, port)}
+}
+
+func (_c *MockManager_GetRdmaDevPkeys_Call) Run(run func(rdmaDev string, port string)) *MockManager_GetRdmaDevPkeys_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockManager_GetRdmaDevPkeys_Call) Return(vs []uint16, err error) *MockManager_GetRdmaDevPkeys_Call {
+	_c.Call.Return(vs, err)
+	return _c
+}
+
+func (_c *MockManager_GetRdmaDevPkeys_Call) RunAndReturn(run func(rdmaDev string, port string) ([]uint16, error)) *MockManager_GetRdmaDevPkeys_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRdmaDevPorts provides a mock function for the type MockManager
+func (_mock *MockManager) GetRdmaDevPorts(rdmaDev string) []string {
+	ret := _mock.Called(rdmaDev)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRdmaDevPorts")
+	}
+
+	var r0 []string
+	if returnFunc, ok := ret.Get(0).(func(string) []string); ok {
+		r0 = returnFunc(rdmaDev)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	return r0
+}
+
+// MockManager_GetRdmaDevPorts_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRdmaDevPorts'
+type MockManager_GetRdmaDevPorts_Call struct {
+	*mock.Call
+}
+
+// GetRdmaDevPorts is a helper method to define mock.On call
+//   - rdmaDev string
+func (_e *MockManager_Expecter) GetRdmaDevPorts(rdmaDev interface{}) *MockManager_GetRdmaDevPorts_Call {
+	return &MockManager_GetRdmaDevPorts_Call{Call: _e.mock.On("GetRdmaDevPorts", rdmaDev)}
+}
+
+func (_c *MockManager_GetRdmaDevPorts_Call) Run(run func(rdmaDev string)) *MockManager_GetRdmaDevPorts_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockManager_GetRdmaDevPorts_Call) Return(strings []string) *MockManager_GetRdmaDevPorts_Call {
+	_c.Call.Return(strings)
+	return _c
+}
+
+func (_c *MockManager_GetRdmaDevPorts_Call) RunAndReturn(run func(rdmaDev string) []string) *MockManager_GetRdmaDevPorts_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetRdmaDevs provides a mock function for the type MockManager
+func (_mock *MockManager) GetRdmaDevs() ([]string, error) {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetRdmaDevs")
+	}
+
+	var r0 []string
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func() ([]string, error)); ok {
+		return returnFunc()
+	}
+	if returnFunc, ok := ret.Get(0).(func() []string); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func() error); ok {
+		r1 = returnFunc()
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockManager_GetRdmaDevs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetRdmaDevs'
+type MockManager_GetRdmaDevs_Call struct {
+	*mock.Call
+}
+
+// GetRdmaDevs is a helper method to define mock.On call
+func (_e *MockManager_Expecter) GetRdmaDevs() *MockManager_GetRdmaDevs_Call {
+	return &MockManager_GetRdmaDevs_Call{Call: _e.mock.On("GetRdmaDevs")}
+}
+
+func (_c *MockManager_GetRdmaDevs_Call) Run(run func()) *MockManager_GetRdmaDevs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *MockManager_GetRdmaDevs_Call) Return(strings []string, err error) *MockManager_GetRdmaDevs_Call {
+	_c.Call.Return(strings, err)
+	return _c
+}
+
+func (_c *MockManager_GetRdmaDevs_Call) RunAndReturn(run func() ([]string, error)) *MockManager_GetRdmaDevs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GetRdmaDevsForAuxDev provides a mock function for the type MockManager
 func (_mock *MockManager) GetRdmaDevsForAuxDev(auxDev string) []string {
 	ret := _mock.Called(auxDev)
@@ -252,6 +613,63 @@ func (_c *MockManager_MoveRdmaDevToNs_Call) RunAndReturn(run func(rdmaDev string
 	return _c
 }
 
+// RenameRdmaDev provides a mock function for the type MockManager
+func (_mock *MockManager) RenameRdmaDev(rdmaDev string, newName string) error {
+	ret := _mock.Called(rdmaDev, newName)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RenameRdmaDev")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = returnFunc(rdmaDev, newName)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockManager_RenameRdmaDev_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RenameRdmaDev'
+type MockManager_RenameRdmaDev_Call struct {
+	*mock.Call
+}
+
+// RenameRdmaDev is a helper method to define mock.On call
+//   - rdmaDev string
+//   - newName string
+func (_e *MockManager_Expecter) RenameRdmaDev(rdmaDev interface{}, newName interface{}) *MockManager_RenameRdmaDev_Call {
+	return &MockManager_RenameRdmaDev_Call{Call: _e.mock.On("RenameRdmaDev", rdmaDev, newName)}
+}
+
+func (_c *MockManager_RenameRdmaDev_Call) Run(run func(rdmaDev string, newName string)) *MockManager_RenameRdmaDev_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockManager_RenameRdmaDev_Call) Return(err error) *MockManager_RenameRdmaDev_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockManager_RenameRdmaDev_Call) RunAndReturn(run func(rdmaDev string, newName string) error) *MockManager_RenameRdmaDev_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SetSystemRdmaMode provides a mock function for the type MockManager
 func (_mock *MockManager) SetSystemRdmaMode(mode string) error {
 	ret := _mock.Called(mode)
@@ -302,3 +720,235 @@ func (_c *MockManager_SetSystemRdmaMode_Call) RunAndReturn(run func(mode string)
 	_c.Call.Return(run)
 	return _c
 }
+
+// SetVfGUID provides a mock function for the type MockManager
+func (_mock *MockManager) SetVfGUID(vfPciDev string, guid net.HardwareAddr) error {
+	ret := _mock.Called(vfPciDev, guid)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetVfGUID")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string, net.HardwareAddr) error); ok {
+		r0 = returnFunc(vfPciDev, guid)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockManager_SetVfGUID_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetVfGUID'
+type MockManager_SetVfGUID_Call struct {
+	*mock.Call
+}
+
+// SetVfGUID is a helper method to define mock.On call
+//   - vfPciDev string
+//   - guid net.HardwareAddr
+func (_e *MockManager_Expecter) SetVfGUID(vfPciDev interface{}, guid interface{}) *MockManager_SetVfGUID_Call {
+	return &MockManager_SetVfGUID_Call{Call: _e.mock.On("SetVfGUID", vfPciDev, guid)}
+}
+
+func (_c *MockManager_SetVfGUID_Call) Run(run func(vfPciDev string, guid net.HardwareAddr)) *MockManager_SetVfGUID_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 net.HardwareAddr
+		if args[1] != nil {
+			arg1 = args[1].(net.HardwareAddr)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockManager_SetVfGUID_Call) Return(err error) *MockManager_SetVfGUID_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockManager_SetVfGUID_Call) RunAndReturn(run func(vfPciDev string, guid net.HardwareAddr) error) *MockManager_SetVfGUID_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WaitRdmaDev provides a mock function for the type MockManager
+func (_mock *MockManager) WaitRdmaDev(rdmaDev string, timeout time.Duration) error {
+	ret := _mock.Called(rdmaDev, timeout)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WaitRdmaDev")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(string, time.Duration) error); ok {
+		r0 = returnFunc(rdmaDev, timeout)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockManager_WaitRdmaDev_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WaitRdmaDev'
+type MockManager_WaitRdmaDev_Call struct {
+	*mock.Call
+}
+
+// WaitRdmaDev is a helper method to define mock.On call
+//   - rdmaDev string
+//   - timeout time.Duration
+func (_e *MockManager_Expecter) WaitRdmaDev(rdmaDev interface{}, timeout interface{}) *MockManager_WaitRdmaDev_Call {
+	return &MockManager_WaitRdmaDev_Call{Call: _e.mock.On("WaitRdmaDev", rdmaDev, timeout)}
+}
+
+func (_c *MockManager_WaitRdmaDev_Call) Run(run func(rdmaDev string, timeout time.Duration)) *MockManager_WaitRdmaDev_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 time.Duration
+		if args[1] != nil {
+			arg1 = args[1].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockManager_WaitRdmaDev_Call) Return(err error) *MockManager_WaitRdmaDev_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockManager_WaitRdmaDev_Call) RunAndReturn(run func(rdmaDev string, timeout time.Duration) error) *MockManager_WaitRdmaDev_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WaitRdmaDevsForAuxDev provides a mock function for the type MockManager
+func (_mock *MockManager) WaitRdmaDevsForAuxDev(auxDev string, timeout time.Duration) []string {
+	ret := _mock.Called(auxDev, timeout)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WaitRdmaDevsForAuxDev")
+	}
+
+	var r0 []string
+	if returnFunc, ok := ret.Get(0).(func(string, time.Duration) []string); ok {
+		r0 = returnFunc(auxDev, timeout)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	return r0
+}
+
+// MockManager_WaitRdmaDevsForAuxDev_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WaitRdmaDevsForAuxDev'
+type MockManager_WaitRdmaDevsForAuxDev_Call struct {
+	*mock.Call
+}
+
+// WaitRdmaDevsForAuxDev is a helper method to define mock.On call
+//   - auxDev string
+//   - timeout time.Duration
+func (_e *MockManager_Expecter) WaitRdmaDevsForAuxDev(auxDev interface{}, timeout interface{}) *MockManager_WaitRdmaDevsForAuxDev_Call {
+	return &MockManager_WaitRdmaDevsForAuxDev_Call{Call: _e.mock.On("WaitRdmaDevsForAuxDev", auxDev, timeout)}
+}
+
+func (_c *MockManager_WaitRdmaDevsForAuxDev_Call) Run(run func(auxDev string, timeout time.Duration)) *MockManager_WaitRdmaDevsForAuxDev_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 time.Duration
+		if args[1] != nil {
+			arg1 = args[1].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockManager_WaitRdmaDevsForAuxDev_Call) Return(strings []string) *MockManager_WaitRdmaDevsForAuxDev_Call {
+	_c.Call.Return(strings)
+	return _c
+}
+
+func (_c *MockManager_WaitRdmaDevsForAuxDev_Call) RunAndReturn(run func(auxDev string, timeout time.Duration) []string) *MockManager_WaitRdmaDevsForAuxDev_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WaitRdmaDevsForPciDev provides a mock function for the type MockManager
+func (_mock *MockManager) WaitRdmaDevsForPciDev(pciDev string, timeout time.Duration) []string {
+	ret := _mock.Called(pciDev, timeout)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WaitRdmaDevsForPciDev")
+	}
+
+	var r0 []string
+	if returnFunc, ok := ret.Get(0).(func(string, time.Duration) []string); ok {
+		r0 = returnFunc(pciDev, timeout)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+	return r0
+}
+
+// MockManager_WaitRdmaDevsForPciDev_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WaitRdmaDevsForPciDev'
+type MockManager_WaitRdmaDevsForPciDev_Call struct {
+	*mock.Call
+}
+
+// WaitRdmaDevsForPciDev is a helper method to define mock.On call
+//   - pciDev string
+//   - timeout time.Duration
+func (_e *MockManager_Expecter) WaitRdmaDevsForPciDev(pciDev interface{}, timeout interface{}) *MockManager_WaitRdmaDevsForPciDev_Call {
+	return &MockManager_WaitRdmaDevsForPciDev_Call{Call: _e.mock.On("WaitRdmaDevsForPciDev", pciDev, timeout)}
+}
+
+func (_c *MockManager_WaitRdmaDevsForPciDev_Call) Run(run func(pciDev string, timeout time.Duration)) *MockManager_WaitRdmaDevsForPciDev_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		var arg1 time.Duration
+		if args[1] != nil {
+			arg1 = args[1].(time.Duration)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockManager_WaitRdmaDevsForPciDev_Call) Return(strings []string) *MockManager_WaitRdmaDevsForPciDev_Call {
+	_c.Call.Return(strings)
+	return _c
+}
+
+func (_c *MockManager_WaitRdmaDevsForPciDev_Call) RunAndReturn(run func(pciDev string, timeout time.Duration) []string) *MockManager_WaitRdmaDevsForPciDev_Call {
+	_c.Call.Return(run)
+	return _c
+}
@@ -18,13 +18,35 @@ package rdma
 
 import (
 	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+
+	"github.com/k8snetworkplumbingwg/rdma-cni/pkg/types"
 )
 
 const (
 	RdmaSysModeExclusive = "exclusive"
 	RdmaSysModeShared    = "shared"
+
+	parentDevBusPci = "pci"
+	parentDevBusAux = "auxiliary"
+
+	// sysfs attribute of RDMA device node type e.g "1: CA"
+	rdmaDevNodeTypeAttr = "node_type"
+	// Length of InfiniBand GUID in bytes
+	guidLen = 8
+	// sysfs directory of RDMA device port partition key table entries e.g "0xffff"
+	rdmaDevPortPkeysDir = "ports/%s/pkeys"
+
+	// Interval between attempts while waiting for RDMA devices, doubled after each attempt up to maxRetryInterval
+	initialRetryInterval = 50 * time.Millisecond
+	maxRetryInterval     = time.Second
 )
 
 func NewRdmaManager() Manager {
@@ -32,13 +54,39 @@ func NewRdmaManager() Manager {
 }
 
 type Manager interface {
-	// Move RDMA device from current network namespace to network namespace
+	// Move RDMA device from current network namespace to network namespace, no-op if it is already there
 	MoveRdmaDevToNs(rdmaDev string, netNs ns.NetNS) error
+	// Rename RDMA device in current network namespace
+	RenameRdmaDev(rdmaDev string, newName string) error
+	// Get RDMA devices present in the current network namespace
+	GetRdmaDevs() ([]string, error)
 	// Get RDMA devices associated with the given PCI device in D:B:D.f format e.g 0000:04:00.0
 	GetRdmaDevsForPciDev(pciDev string) []string
 	// Get RDMA devices associated with the given auxiliary device. For example, for input mlx5_core.sf.4, returns
 	// [mlx5_0,mlx5_10,..]
 	GetRdmaDevsForAuxDev(auxDev string) []string
+	// Get port numbers of the given RDMA device e.g [1,2]
+	GetRdmaDevPorts(rdmaDev string) []string
+	// Get attributes of the given RDMA device in current namespace. Attributes read from sysfs reflect the
+	// namespace sysfs was mounted in, they are left empty if RDMA device is not visible there
+	GetRdmaDevInfo(rdmaDev string) (*types.RdmaDevInfo, error)
+	// Get RDMA devices associated with the given PCI device, waiting up to timeout for at least one of them
+	// to be registered e.g right after the PCI device was created
+	WaitRdmaDevsForPciDev(pciDev string, timeout time.Duration) []string
+	// Get RDMA devices associated with the given auxiliary device, waiting up to timeout for at least one of them
+	// to be registered
+	WaitRdmaDevsForAuxDev(auxDev string, timeout time.Duration) []string
+	// Validate that the given RDMA device exists in current namespace, waiting up to timeout for it to be registered
+	WaitRdmaDev(rdmaDev string, timeout time.Duration) error
+	// Set node and port GUID of the given InfiniBand VF PCI device via its PF
+	SetVfGUID(vfPciDev string, guid net.HardwareAddr) error
+	// Get partition keys in the partition key table of the given RDMA device port from sysfs, excluding empty entries
+	GetRdmaDevPkeys(rdmaDev, port string) ([]uint16, error)
+	// Get partition key of the given IPoIB network device in current namespace
+	GetIPoIBPkey(netdev string) (uint16, error)
+	// Get the parent device (PCI or auxiliary device) of the given network device in current namespace.
+	// For example, for input eth0, returns 0000:03:00.2
+	GetNetdevParentDev(netdev string) (string, error)
 	// Get RDMA subsystem namespace awareness mode ["exclusive" | "shared"]
 	GetSystemRdmaMode() (string, error)
 	// Set RDMA subsystem namespace awareness mode ["exclusive" | "shared"]
@@ -62,6 +110,32 @@ func (rmn *rdmaManagerNetlink) MoveRdmaDevToNs(rdmaDev string, netNs ns.NetNS) e
 	return nil
 }
 
+// Rename RDMA device in current network namespace
+func (rmn *rdmaManagerNetlink) RenameRdmaDev(rdmaDev, newName string) error {
+	rdmaLink, err := rmn.rdmaOps.RdmaLinkByName(rdmaDev)
+	if err != nil {
+		return fmt.Errorf("cannot find RDMA link from name: %s", rdmaDev)
+	}
+	err = rmn.rdmaOps.RdmaLinkSetName(rdmaLink, newName)
+	if err != nil {
+		return fmt.Errorf("failed to rename RDMA dev %s to %s. %w", rdmaDev, newName, err)
+	}
+	return nil
+}
+
+// Get RDMA devices present in the current network namespace
+func (rmn *rdmaManagerNetlink) GetRdmaDevs() ([]string, error) {
+	rdmaLinks, err := rmn.rdmaOps.RdmaLinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list RDMA links. %v", err)
+	}
+	rdmaDevs := make([]string, 0, len(rdmaLinks))
+	for _, link := range rdmaLinks {
+		rdmaDevs = append(rdmaDevs, link.Attrs.Name)
+	}
+	return rdmaDevs, nil
+}
+
 // Get RDMA device associated with the given PCI device in D:B:D.f format e.g 0000:04:00.1
 func (rmn *rdmaManagerNetlink) GetRdmaDevsForPciDev(pciDev string) []string {
 	return rmn.rdmaOps.GetRdmaDevicesForPcidev(pciDev)
@@ -73,6 +147,173 @@ func (rmn *rdmaManagerNetlink) GetRdmaDevsForAuxDev(auxDev string) []string {
 	return rmn.rdmaOps.GetRdmaDevicesForAuxdev(auxDev)
 }
 
+// Get port numbers of the given RDMA device e.g [1,2]
+func (rmn *rdmaManagerNetlink) GetRdmaDevPorts(rdmaDev string) []string {
+	return rmn.rdmaOps.GetPorts(rdmaDev)
+}
+
+// Validate that the given RDMA device exists in current namespace
+func (rmn *rdmaManagerNetlink) validateRdmaDev(rdmaDev string) error {
+	if _, err := rmn.rdmaOps.RdmaLinkByName(rdmaDev); err != nil {
+		return fmt.Errorf("cannot find RDMA link from name: %s. %w", rdmaDev, err)
+	}
+	return nil
+}
+
+func (rmn *rdmaManagerNetlink) GetRdmaDevInfo(rdmaDev string) (*types.RdmaDevInfo, error) {
+	rdmaLink, err := rmn.rdmaOps.RdmaLinkByName(rdmaDev)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find RDMA link from name: %s. %w", rdmaDev, err)
+	}
+	info := &types.RdmaDevInfo{
+		Name:            rdmaLink.Attrs.Name,
+		NodeGUID:        guidFromNetlink(rdmaLink.Attrs.NodeGuid),
+		SysImageGUID:    guidFromNetlink(rdmaLink.Attrs.SysImageGuid),
+		FirmwareVersion: rdmaLink.Attrs.FirmwareVersion,
+		Ports:           rmn.rdmaOps.GetPorts(rdmaDev),
+	}
+	if info.NodeType, err = rmn.rdmaOps.ReadRdmaDevAttr(rdmaDev, rdmaDevNodeTypeAttr); err != nil {
+		info.NodeType = ""
+	}
+	return info, nil
+}
+
+// ParseGUID parses InfiniBand GUID in network byte order e.g "98:03:9b:03:00:9e:e3:5e"
+func ParseGUID(guid string) (net.HardwareAddr, error) {
+	parsed, err := net.ParseMAC(guid)
+	if err != nil || len(parsed) != guidLen {
+		return nil, fmt.Errorf("invalid GUID %q, expecting 8 bytes e.g 00:11:22:33:44:55:66:77", guid)
+	}
+	return parsed, nil
+}
+
+// Convert GUID reported by netlink, which formats it byte-reversed e.g "5e:e3:9e:00:03:9b:03:98", to network byte
+// order as in sysfs and InfiniBand tools e.g "98:03:9b:03:00:9e:e3:5e". Malformed GUIDs are returned as is
+func guidFromNetlink(netlinkGUID string) string {
+	guid, err := ParseGUID(netlinkGUID)
+	if err != nil {
+		return netlinkGUID
+	}
+	slices.Reverse(guid)
+	return guid.String()
+}
+
+func (rmn *rdmaManagerNetlink) WaitRdmaDevsForPciDev(pciDev string, timeout time.Duration) []string {
+	var rdmaDevs []string
+	waitFor(timeout, func() bool {
+		rdmaDevs = rmn.GetRdmaDevsForPciDev(pciDev)
+		return len(rdmaDevs) > 0
+	})
+	return rdmaDevs
+}
+
+func (rmn *rdmaManagerNetlink) WaitRdmaDevsForAuxDev(auxDev string, timeout time.Duration) []string {
+	var rdmaDevs []string
+	waitFor(timeout, func() bool {
+		rdmaDevs = rmn.GetRdmaDevsForAuxDev(auxDev)
+		return len(rdmaDevs) > 0
+	})
+	return rdmaDevs
+}
+
+func (rmn *rdmaManagerNetlink) WaitRdmaDev(rdmaDev string, timeout time.Duration) error {
+	var err error
+	waitFor(timeout, func() bool {
+		err = rmn.validateRdmaDev(rdmaDev)
+		return err == nil
+	})
+	return err
+}
+
+func (rmn *rdmaManagerNetlink) SetVfGUID(vfPciDev string, guid net.HardwareAddr) error {
+	pfNetdev, vfIndex, err := rmn.rdmaOps.GetPfNetdevAndVfIndex(vfPciDev)
+	if err != nil {
+		return err
+	}
+	pfLink, err := rmn.rdmaOps.LinkByName(pfNetdev)
+	if err != nil {
+		return fmt.Errorf("failed to get PF link %s. %v", pfNetdev, err)
+	}
+	if err = rmn.rdmaOps.LinkSetVfNodeGUID(pfLink, vfIndex, guid); err != nil {
+		return fmt.Errorf("failed to set node GUID of VF %d of PF %s to %s. %v", vfIndex, pfNetdev, guid, err)
+	}
+	if err = rmn.rdmaOps.LinkSetVfPortGUID(pfLink, vfIndex, guid); err != nil {
+		return fmt.Errorf("failed to set port GUID of VF %d of PF %s to %s. %v", vfIndex, pfNetdev, guid, err)
+	}
+	return nil
+}
+
+func (rmn *rdmaManagerNetlink) GetRdmaDevPkeys(rdmaDev, port string) ([]uint16, error) {
+	pkeysDir := fmt.Sprintf(rdmaDevPortPkeysDir, port)
+	indices, err := rmn.rdmaOps.ListRdmaDevAttrs(rdmaDev, pkeysDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pkeys of RDMA device %s port %s. %v", rdmaDev, port, err)
+	}
+	pkeys := make([]uint16, 0, len(indices))
+	for _, index := range indices {
+		value, err := rmn.rdmaOps.ReadRdmaDevAttr(rdmaDev, filepath.Join(pkeysDir, index))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pkey %s of RDMA device %s port %s. %v", index, rdmaDev, port, err)
+		}
+		pkey, err := strconv.ParseUint(value, 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pkey %q of RDMA device %s port %s", value, rdmaDev, port)
+		}
+		if pkey != 0 {
+			pkeys = append(pkeys, uint16(pkey))
+		}
+	}
+	return pkeys, nil
+}
+
+func (rmn *rdmaManagerNetlink) GetIPoIBPkey(netdev string) (uint16, error) {
+	link, err := rmn.rdmaOps.LinkByName(netdev)
+	if err != nil {
+		return 0, fmt.Errorf("cannot find link from name: %s. %w", netdev, err)
+	}
+	ipoib, ok := link.(*netlink.IPoIB)
+	if !ok {
+		return 0, fmt.Errorf("link %s is of type %s, expecting ipoib", netdev, link.Type())
+	}
+	return ipoib.Pkey, nil
+}
+
+// Call ready until it returns true or timeout expires, with exponential backoff between attempts.
+// ready is called at least once, returns whether it returned true
+func waitFor(timeout time.Duration, ready func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	interval := initialRetryInterval
+	for {
+		if ready() {
+			return true
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+		time.Sleep(min(interval, remaining))
+		interval = min(2*interval, maxRetryInterval)
+	}
+}
+
+// Get the parent device (PCI or auxiliary device) of the given network device in current namespace.
+// sysfs reflects the network namespace it was mounted in, hence the parent device is retrieved via netlink
+func (rmn *rdmaManagerNetlink) GetNetdevParentDev(netdev string) (string, error) {
+	link, err := rmn.rdmaOps.LinkByName(netdev)
+	if err != nil {
+		return "", fmt.Errorf("cannot find link from name: %s. %w", netdev, err)
+	}
+	attrs := link.Attrs()
+	if attrs.ParentDev == "" {
+		return "", fmt.Errorf("parent device of link %s is unknown", netdev)
+	}
+	if attrs.ParentDevBus != parentDevBusPci && attrs.ParentDevBus != parentDevBusAux {
+		return "", fmt.Errorf("parent device %s of link %s is on unsupported bus %q",
+			attrs.ParentDev, netdev, attrs.ParentDevBus)
+	}
+	return attrs.ParentDev, nil
+}
+
 // Get RDMA subsystem namespace awareness mode ["exclusive" | "shared"]
 func (rmn *rdmaManagerNetlink) GetSystemRdmaMode() (string, error) {
 	return rmn.rdmaOps.RdmaSystemGetNetnsMode()
@@ -17,16 +17,27 @@
 package rdma
 
 import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/Mellanox/rdmamap"
 	"github.com/vishvananda/netlink"
+
+	"github.com/k8snetworkplumbingwg/rdma-cni/pkg/utils"
 )
 
 // Interface to be used by RDMA manager for basic operations
 type BasicOps interface {
+	// Equivalent to netlink.RdmaLinkList(...)
+	RdmaLinkList() ([]*netlink.RdmaLink, error)
 	// Equivalent to netlink.RdmaLinkByName(...)
 	RdmaLinkByName(name string) (*netlink.RdmaLink, error)
 	// Equivalent to netlink.RdmaLinkSetNsFd(...)
 	RdmaLinkSetNsFd(link *netlink.RdmaLink, fd uint32) error
+	// Equivalent to netlink.RdmaLinkSetName(...)
+	RdmaLinkSetName(link *netlink.RdmaLink, name string) error
 	// Equivalent to netlink.RdmaSystemGetNetnsMode(...)
 	RdmaSystemGetNetnsMode() (string, error)
 	// Equivalent to netlink.RdmaSystemSetNetnsMode(...)
@@ -35,6 +46,22 @@ type BasicOps interface {
 	GetRdmaDevicesForPcidev(pcidevName string) []string
 	// Equivalent to rdmamap.GetRdmaDevicesForAuxdev(...)
 	GetRdmaDevicesForAuxdev(auxDev string) []string
+	// Equivalent to rdmamap.GetPorts(...)
+	GetPorts(rdmaDeviceName string) []string
+	// Equivalent to netlink.LinkByName(...)
+	LinkByName(name string) (netlink.Link, error)
+	// Equivalent to netlink.LinkSetVfNodeGUID(...)
+	LinkSetVfNodeGUID(link netlink.Link, vf int, nodeguid net.HardwareAddr) error
+	// Equivalent to netlink.LinkSetVfPortGUID(...)
+	LinkSetVfPortGUID(link netlink.Link, vf int, portguid net.HardwareAddr) error
+	// Equivalent to utils.GetPfNetdevAndVfIndex(...)
+	GetPfNetdevAndVfIndex(vfPciDev string) (string, int, error)
+	// Read attribute of RDMA device from sysfs, given by its path relative to the RDMA device directory
+	// e.g node_type
+	ReadRdmaDevAttr(rdmaDeviceName, attr string) (string, error)
+	// List attributes of RDMA device in sysfs directory, given by its path relative to the RDMA device directory
+	// e.g ports/1/pkeys
+	ListRdmaDevAttrs(rdmaDeviceName, dir string) ([]string, error)
 }
 
 func newRdmaBasicOps() BasicOps {
@@ -44,6 +71,11 @@ func newRdmaBasicOps() BasicOps {
 type rdmaBasicOpsImpl struct {
 }
 
+// Equivalent to netlink.RdmaLinkList(...)
+func (rdma *rdmaBasicOpsImpl) RdmaLinkList() ([]*netlink.RdmaLink, error) {
+	return netlink.RdmaLinkList()
+}
+
 // Equivalent to netlink.RdmaLinkByName(...)
 func (rdma *rdmaBasicOpsImpl) RdmaLinkByName(name string) (*netlink.RdmaLink, error) {
 	return netlink.RdmaLinkByName(name)
@@ -54,6 +86,11 @@ func (rdma *rdmaBasicOpsImpl) RdmaLinkSetNsFd(link *netlink.RdmaLink, fd uint32)
 	return netlink.RdmaLinkSetNsFd(link, fd)
 }
 
+// Equivalent to netlink.RdmaLinkSetName(...)
+func (rdma *rdmaBasicOpsImpl) RdmaLinkSetName(link *netlink.RdmaLink, name string) error {
+	return netlink.RdmaLinkSetName(link, name)
+}
+
 // Equivalent to netlink.RdmaSystemGetNetnsMode(...)
 func (rdma *rdmaBasicOpsImpl) RdmaSystemGetNetnsMode() (string, error) {
 	return netlink.RdmaSystemGetNetnsMode()
@@ -73,3 +110,50 @@ func (rdma *rdmaBasicOpsImpl) GetRdmaDevicesForPcidev(pcidevName string) []strin
 func (rdma *rdmaBasicOpsImpl) GetRdmaDevicesForAuxdev(auxDev string) []string {
 	return rdmamap.GetRdmaDevicesForAuxdev(auxDev)
 }
+
+// Equivalent to rdmamap.GetPorts(...)
+func (rdma *rdmaBasicOpsImpl) GetPorts(rdmaDeviceName string) []string {
+	return rdmamap.GetPorts(rdmaDeviceName)
+}
+
+// Equivalent to netlink.LinkByName(...)
+func (rdma *rdmaBasicOpsImpl) LinkByName(name string) (netlink.Link, error) {
+	return netlink.LinkByName(name)
+}
+
+// Equivalent to netlink.LinkSetVfNodeGUID(...)
+func (rdma *rdmaBasicOpsImpl) LinkSetVfNodeGUID(link netlink.Link, vf int, nodeguid net.HardwareAddr) error {
+	return netlink.LinkSetVfNodeGUID(link, vf, nodeguid)
+}
+
+// Equivalent to netlink.LinkSetVfPortGUID(...)
+func (rdma *rdmaBasicOpsImpl) LinkSetVfPortGUID(link netlink.Link, vf int, portguid net.HardwareAddr) error {
+	return netlink.LinkSetVfPortGUID(link, vf, portguid)
+}
+
+// Equivalent to utils.GetPfNetdevAndVfIndex(...)
+func (rdma *rdmaBasicOpsImpl) GetPfNetdevAndVfIndex(vfPciDev string) (string, int, error) {
+	return utils.GetPfNetdevAndVfIndex(vfPciDev)
+}
+
+// Read attribute of RDMA device from sysfs, given by its path relative to the RDMA device directory
+func (rdma *rdmaBasicOpsImpl) ReadRdmaDevAttr(rdmaDeviceName, attr string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(rdmamap.RdmaClassDir, rdmaDeviceName, attr))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// List attributes of RDMA device in sysfs directory, given by its path relative to the RDMA device directory
+func (rdma *rdmaBasicOpsImpl) ListRdmaDevAttrs(rdmaDeviceName, dir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(rdmamap.RdmaClassDir, rdmaDeviceName, dir))
+	if err != nil {
+		return nil, err
+	}
+	attrs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		attrs = append(attrs, entry.Name())
+	}
+	return attrs, nil
+}
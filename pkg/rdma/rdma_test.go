@@ -17,7 +17,11 @@
 package rdma
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"syscall"
+	"time"
 
 	"github.com/containernetworking/plugins/pkg/ns"
 	. "github.com/onsi/ginkgo/v2"
@@ -26,6 +30,7 @@ import (
 	"github.com/vishvananda/netlink"
 
 	"github.com/k8snetworkplumbingwg/rdma-cni/pkg/rdma/mocks"
+	"github.com/k8snetworkplumbingwg/rdma-cni/pkg/types"
 )
 
 type dummyNetNs struct {
@@ -37,6 +42,10 @@ func (dns *dummyNetNs) Fd() uintptr {
 	return dns.fd
 }
 
+func (dns *dummyNetNs) Do(toRun func(ns.NetNS) error) error {
+	return toRun(dns)
+}
+
 var _ = Describe("Rdma Manager", func() {
 	var (
 		rdmaManager Manager
@@ -71,6 +80,281 @@ var _ = Describe("Rdma Manager", func() {
 		})
 	})
 
+	Describe("Test GetRdmaDevPorts()", func() {
+		Context("Basic Call", func() {
+			It("Should return ports as provided by rdmaBasicOps", func() {
+				retVal := []string{"1", "2"}
+				rdmaOpsMock.On("GetPorts", "mlx5_3").Return(retVal)
+				ret := rdmaManager.GetRdmaDevPorts("mlx5_3")
+				rdmaOpsMock.AssertExpectations(t)
+				Expect(ret).To(Equal(retVal))
+			})
+		})
+	})
+
+	Describe("Test validateRdmaDev()", func() {
+		It("Should succeed if rdma link exists", func() {
+			rdmaOpsMock.On("RdmaLinkByName", "mlx5_9").Return(&netlink.RdmaLink{}, nil)
+			Expect(rdmaManager.(*rdmaManagerNetlink).validateRdmaDev("mlx5_9")).To(Succeed())
+			rdmaOpsMock.AssertExpectations(t)
+		})
+		It("Should fail if rdma link cannot be retrieved", func() {
+			rdmaOpsMock.On("RdmaLinkByName", "mlx5_9").Return(nil, syscall.ENODEV)
+			err := rdmaManager.(*rdmaManagerNetlink).validateRdmaDev("mlx5_9")
+			rdmaOpsMock.AssertExpectations(t)
+			Expect(errors.Is(err, syscall.ENODEV)).To(BeTrue())
+		})
+	})
+
+	Describe("Test GetRdmaDevInfo()", func() {
+		It("Should return attributes of rdma link", func() {
+			rdmaOpsMock.On("RdmaLinkByName", "mlx5_9").Return(&netlink.RdmaLink{Attrs: netlink.RdmaLinkAttrs{
+				Name: "mlx5_9", NodeGuid: "5e:e3:9e:00:03:9b:03:98", SysImageGuid: "5c:e3:9e:00:03:9b:03:98",
+				FirmwareVersion: "22.39.1002", NumPorts: 1}}, nil)
+			rdmaOpsMock.On("GetPorts", "mlx5_9").Return([]string{"1"})
+			rdmaOpsMock.On("ReadRdmaDevAttr", "mlx5_9", "node_type").Return("1: CA", nil)
+			info, err := rdmaManager.GetRdmaDevInfo("mlx5_9")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info).To(Equal(&types.RdmaDevInfo{Name: "mlx5_9", NodeGUID: "98:03:9b:03:00:9e:e3:5e",
+				SysImageGUID: "98:03:9b:03:00:9e:e3:5c", FirmwareVersion: "22.39.1002", NodeType: "1: CA",
+				Ports: []string{"1"}}))
+		})
+		It("Should leave sysfs attributes empty if rdma link is not in sysfs", func() {
+			rdmaOpsMock.On("RdmaLinkByName", "mlx5_9").Return(&netlink.RdmaLink{Attrs: netlink.RdmaLinkAttrs{
+				Name: "mlx5_9", NodeGuid: "5e:e3:9e:00:03:9b:03:98"}}, nil)
+			rdmaOpsMock.On("GetPorts", "mlx5_9").Return([]string{})
+			rdmaOpsMock.On("ReadRdmaDevAttr", "mlx5_9", "node_type").Return("", syscall.ENOENT)
+			info, err := rdmaManager.GetRdmaDevInfo("mlx5_9")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(info.NodeGUID).To(Equal("98:03:9b:03:00:9e:e3:5e"))
+			Expect(info.NodeType).To(BeEmpty())
+		})
+		It("Should fail if rdma link cannot be retrieved", func() {
+			rdmaOpsMock.On("RdmaLinkByName", "mlx5_9").Return(nil, syscall.ENODEV)
+			_, err := rdmaManager.GetRdmaDevInfo("mlx5_9")
+			Expect(errors.Is(err, syscall.ENODEV)).To(BeTrue())
+		})
+	})
+
+	Describe("Test ParseGUID()", func() {
+		It("Should parse GUID", func() {
+			guid, err := ParseGUID("98:03:9b:03:00:9e:e3:5e")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(guid).To(Equal(net.HardwareAddr{0x98, 0x03, 0x9b, 0x03, 0x00, 0x9e, 0xe3, 0x5e}))
+		})
+		It("Should fail on MAC address", func() {
+			_, err := ParseGUID("98:03:9b:9e:e3:5e")
+			Expect(err).To(HaveOccurred())
+		})
+		It("Should fail on malformed GUID", func() {
+			_, err := ParseGUID("9803:9b03")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Test SetVfGUID()", func() {
+		guid, _ := net.ParseMAC("00:11:22:33:44:55:66:77")
+
+		It("Should set node and port GUID of VF via its PF", func() {
+			pfLink := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "ib0"}}
+			rdmaOpsMock.On("GetPfNetdevAndVfIndex", "0000:04:00.2").Return("ib0", 1, nil)
+			rdmaOpsMock.On("LinkByName", "ib0").Return(pfLink, nil)
+			rdmaOpsMock.On("LinkSetVfNodeGUID", pfLink, 1, guid).Return(nil)
+			rdmaOpsMock.On("LinkSetVfPortGUID", pfLink, 1, guid).Return(nil)
+			Expect(rdmaManager.SetVfGUID("0000:04:00.2", guid)).To(Succeed())
+			rdmaOpsMock.AssertExpectations(t)
+		})
+		It("Should fail if PCI device is not a VF", func() {
+			rdmaOpsMock.On("GetPfNetdevAndVfIndex", "0000:04:00.0").Return("", 0, fmt.Errorf("not a VF"))
+			Expect(rdmaManager.SetVfGUID("0000:04:00.0", guid)).ToNot(Succeed())
+			rdmaOpsMock.AssertNotCalled(t, "LinkSetVfNodeGUID", mock.Anything, mock.Anything, mock.Anything)
+		})
+		It("Should fail if node GUID cannot be set", func() {
+			pfLink := &netlink.Device{LinkAttrs: netlink.LinkAttrs{Name: "ib0"}}
+			rdmaOpsMock.On("GetPfNetdevAndVfIndex", "0000:04:00.2").Return("ib0", 1, nil)
+			rdmaOpsMock.On("LinkByName", "ib0").Return(pfLink, nil)
+			rdmaOpsMock.On("LinkSetVfNodeGUID", pfLink, 1, guid).Return(syscall.EOPNOTSUPP)
+			Expect(rdmaManager.SetVfGUID("0000:04:00.2", guid)).ToNot(Succeed())
+			rdmaOpsMock.AssertNotCalled(t, "LinkSetVfPortGUID", mock.Anything, mock.Anything, mock.Anything)
+		})
+	})
+
+	Describe("Test GetRdmaDevPkeys()", func() {
+		It("Should return non empty pkey table entries read from sysfs", func() {
+			rdmaOpsMock.On("ListRdmaDevAttrs", "mlx5_9", "ports/1/pkeys").Return([]string{"0", "1", "2"}, nil)
+			rdmaOpsMock.On("ReadRdmaDevAttr", "mlx5_9", "ports/1/pkeys/0").Return("0xffff", nil)
+			rdmaOpsMock.On("ReadRdmaDevAttr", "mlx5_9", "ports/1/pkeys/1").Return("0x8001", nil)
+			rdmaOpsMock.On("ReadRdmaDevAttr", "mlx5_9", "ports/1/pkeys/2").Return("0x0000", nil)
+			pkeys, err := rdmaManager.GetRdmaDevPkeys("mlx5_9", "1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pkeys).To(Equal([]uint16{0xffff, 0x8001}))
+		})
+		It("Should fail if pkey table cannot be listed", func() {
+			rdmaOpsMock.On("ListRdmaDevAttrs", "mlx5_9", "ports/1/pkeys").Return(nil, syscall.ENOENT)
+			_, err := rdmaManager.GetRdmaDevPkeys("mlx5_9", "1")
+			Expect(err).To(HaveOccurred())
+		})
+		It("Should fail on malformed pkey", func() {
+			rdmaOpsMock.On("ListRdmaDevAttrs", "mlx5_9", "ports/1/pkeys").Return([]string{"0"}, nil)
+			rdmaOpsMock.On("ReadRdmaDevAttr", "mlx5_9", "ports/1/pkeys/0").Return("0x1ffff", nil)
+			_, err := rdmaManager.GetRdmaDevPkeys("mlx5_9", "1")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Test GetIPoIBPkey()", func() {
+		It("Should return pkey of IPoIB link", func() {
+			rdmaOpsMock.On("LinkByName", "ib0.8001").Return(&netlink.IPoIB{Pkey: 0x8001}, nil)
+			pkey, err := rdmaManager.GetIPoIBPkey("ib0.8001")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pkey).To(Equal(uint16(0x8001)))
+		})
+		It("Should fail if link is not an IPoIB link", func() {
+			rdmaOpsMock.On("LinkByName", "eth0").Return(&netlink.Device{}, nil)
+			_, err := rdmaManager.GetIPoIBPkey("eth0")
+			Expect(err).To(HaveOccurred())
+		})
+		It("Should fail if link cannot be retrieved", func() {
+			rdmaOpsMock.On("LinkByName", "ib0.8001").Return(nil, syscall.ENODEV)
+			_, err := rdmaManager.GetIPoIBPkey("ib0.8001")
+			Expect(errors.Is(err, syscall.ENODEV)).To(BeTrue())
+		})
+	})
+
+	Describe("Test WaitRdmaDevsForPciDev()", func() {
+		It("Should return RDMA devices once they are registered", func() {
+			rdmaOpsMock.On("GetRdmaDevicesForPcidev", "0000:04:00.1").Return([]string{}).Twice()
+			rdmaOpsMock.On("GetRdmaDevicesForPcidev", "0000:04:00.1").Return([]string{"mlx5_3"}).Once()
+			ret := rdmaManager.WaitRdmaDevsForPciDev("0000:04:00.1", time.Second)
+			rdmaOpsMock.AssertExpectations(t)
+			Expect(ret).To(Equal([]string{"mlx5_3"}))
+		})
+		It("Should return no RDMA devices if none are registered before timeout", func() {
+			rdmaOpsMock.On("GetRdmaDevicesForPcidev", "0000:04:00.1").Return([]string{})
+			start := time.Now()
+			ret := rdmaManager.WaitRdmaDevsForPciDev("0000:04:00.1", 200*time.Millisecond)
+			Expect(ret).To(BeEmpty())
+			Expect(time.Since(start)).To(BeNumerically(">=", 200*time.Millisecond))
+			// Backoff between attempts, starting at initialRetryInterval
+			Expect(len(rdmaOpsMock.Calls)).To(BeNumerically("<=", 4))
+		})
+		It("Should look up RDMA devices once if timeout is zero", func() {
+			rdmaOpsMock.On("GetRdmaDevicesForPcidev", "0000:04:00.1").Return([]string{}).Once()
+			Expect(rdmaManager.WaitRdmaDevsForPciDev("0000:04:00.1", 0)).To(BeEmpty())
+			rdmaOpsMock.AssertExpectations(t)
+		})
+	})
+
+	Describe("Test WaitRdmaDevsForAuxDev()", func() {
+		It("Should return RDMA devices once they are registered", func() {
+			rdmaOpsMock.On("GetRdmaDevicesForAuxdev", "mlx5_core.sf.4").Return([]string{}).Once()
+			rdmaOpsMock.On("GetRdmaDevicesForAuxdev", "mlx5_core.sf.4").Return([]string{"mlx5_4"}).Once()
+			ret := rdmaManager.WaitRdmaDevsForAuxDev("mlx5_core.sf.4", time.Second)
+			rdmaOpsMock.AssertExpectations(t)
+			Expect(ret).To(Equal([]string{"mlx5_4"}))
+		})
+	})
+
+	Describe("Test WaitRdmaDev()", func() {
+		It("Should succeed once rdma link exists", func() {
+			rdmaOpsMock.On("RdmaLinkByName", "mlx5_9").Return(nil, syscall.ENODEV).Once()
+			rdmaOpsMock.On("RdmaLinkByName", "mlx5_9").Return(&netlink.RdmaLink{}, nil).Once()
+			Expect(rdmaManager.WaitRdmaDev("mlx5_9", time.Second)).To(Succeed())
+			rdmaOpsMock.AssertExpectations(t)
+		})
+		It("Should fail with last error if rdma link does not exist before timeout", func() {
+			rdmaOpsMock.On("RdmaLinkByName", "mlx5_9").Return(nil, syscall.ENODEV)
+			err := rdmaManager.WaitRdmaDev("mlx5_9", 100*time.Millisecond)
+			Expect(errors.Is(err, syscall.ENODEV)).To(BeTrue())
+		})
+	})
+
+	Describe("Test GetNetdevParentDev()", func() {
+		It("Should return PCI parent device of the link", func() {
+			link := &netlink.Device{LinkAttrs: netlink.LinkAttrs{ParentDev: "0000:03:00.2", ParentDevBus: "pci"}}
+			rdmaOpsMock.On("LinkByName", "eth0").Return(link, nil)
+			parentDev, err := rdmaManager.GetNetdevParentDev("eth0")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parentDev).To(Equal("0000:03:00.2"))
+		})
+		It("Should return auxiliary parent device of the link", func() {
+			link := &netlink.Device{LinkAttrs: netlink.LinkAttrs{ParentDev: "mlx5_core.sf.4", ParentDevBus: "auxiliary"}}
+			rdmaOpsMock.On("LinkByName", "eth0").Return(link, nil)
+			parentDev, err := rdmaManager.GetNetdevParentDev("eth0")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parentDev).To(Equal("mlx5_core.sf.4"))
+		})
+		It("Should fail if parent device of the link is unknown", func() {
+			rdmaOpsMock.On("LinkByName", "eth0").Return(&netlink.Device{}, nil)
+			_, err := rdmaManager.GetNetdevParentDev("eth0")
+			Expect(err).To(HaveOccurred())
+		})
+		It("Should fail if parent device of the link is on unsupported bus", func() {
+			link := &netlink.Device{LinkAttrs: netlink.LinkAttrs{ParentDev: "virtio0", ParentDevBus: "virtio"}}
+			rdmaOpsMock.On("LinkByName", "eth0").Return(link, nil)
+			_, err := rdmaManager.GetNetdevParentDev("eth0")
+			Expect(err).To(HaveOccurred())
+		})
+		It("Should fail if link cannot be retrieved", func() {
+			rdmaOpsMock.On("LinkByName", "eth0").Return(nil, syscall.ENODEV)
+			_, err := rdmaManager.GetNetdevParentDev("eth0")
+			Expect(errors.Is(err, syscall.ENODEV)).To(BeTrue())
+		})
+	})
+
+	Describe("Test RenameRdmaDev()", func() {
+		Context("Basic Call - no error", func() {
+			It("Calls rdmaOps.RdmaLinkSetName with the rdma Link and the new name", func() {
+				link := &netlink.RdmaLink{}
+				rdmaOpsMock.On("RdmaLinkByName", "mlx5_9").Return(link, nil)
+				rdmaOpsMock.On("RdmaLinkSetName", link, "rdma0").Return(nil)
+				err := rdmaManager.RenameRdmaDev("mlx5_9", "rdma0")
+				rdmaOpsMock.AssertExpectations(t)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+		Context("Basic Call - with error", func() {
+			It("returns error in case rdma link cannot be retrieved", func() {
+				rdmaOpsMock.On("RdmaLinkByName", "mlx5_9").Return(nil, fmt.Errorf("error"))
+				err := rdmaManager.RenameRdmaDev("mlx5_9", "rdma0")
+				rdmaOpsMock.AssertExpectations(t)
+				Expect(err).To(HaveOccurred())
+			})
+			It("returns wrapped error in case rdma link fails to be renamed", func() {
+				link := &netlink.RdmaLink{}
+				rdmaOpsMock.On("RdmaLinkByName", "mlx5_9").Return(link, nil)
+				rdmaOpsMock.On("RdmaLinkSetName", link, "rdma0").Return(syscall.EEXIST)
+				err := rdmaManager.RenameRdmaDev("mlx5_9", "rdma0")
+				rdmaOpsMock.AssertExpectations(t)
+				Expect(errors.Is(err, syscall.EEXIST)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("Test GetRdmaDevs()", func() {
+		Context("Basic Call - no error", func() {
+			It("Should return names of RDMA links as provided by rdmaBasicOps", func() {
+				links := []*netlink.RdmaLink{
+					{Attrs: netlink.RdmaLinkAttrs{Name: "mlx5_0"}},
+					{Attrs: netlink.RdmaLinkAttrs{Name: "mlx5_1"}},
+				}
+				rdmaOpsMock.On("RdmaLinkList").Return(links, nil)
+				ret, err := rdmaManager.GetRdmaDevs()
+				rdmaOpsMock.AssertExpectations(t)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(ret).To(Equal([]string{"mlx5_0", "mlx5_1"}))
+			})
+		})
+		Context("Basic Call - with error", func() {
+			It("Should return error", func() {
+				rdmaOpsMock.On("RdmaLinkList").Return(nil, fmt.Errorf("error"))
+				_, err := rdmaManager.GetRdmaDevs()
+				rdmaOpsMock.AssertExpectations(t)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
 	Describe("Test GetSystemRdmaMode()", func() {
 		Context("Basic Call - no error", func() {
 			It("Is a Proxy for RdmaBasicOps.GetSystemRdmaMode", func() {
@@ -0,0 +1,94 @@
+// Copyright 2025 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedStateVersion is returned when loading RDMA network state of a version which cannot be migrated
+var ErrUnsupportedStateVersion = errors.New("unsupported RDMA network state version")
+
+// Migrations of RDMA network state from each minor version to the next one, indexed by the minor version
+// they migrate from. A migration must be added whenever the minor version is bumped
+var rdmaNetStateMigrations = []func(s *RdmaNetState){
+	// 1.0 -> 1.1: attachment (network, container ID, interface name, netns) was not recorded, left empty
+	func(_ *RdmaNetState) {},
+	// 1.1 -> 1.2: a single RDMA device was recorded
+	func(s *RdmaNetState) {
+		s.SetRdmaDevs([]RdmaDevState{
+			{SandboxRdmaDevName: s.SandboxRdmaDevName, ContainerRdmaDevName: s.ContainerRdmaDevName}})
+	},
+	// 1.2 -> 1.3: only exclusive mode was supported, which is the empty mode
+	func(_ *RdmaNetState) {},
+	// 1.3 -> 1.4: node GUID of RDMA devices was not recorded, left empty so they are not verified
+	func(_ *RdmaNetState) {},
+	// 1.4 -> 1.5: GUID of the VF could not be set
+	func(_ *RdmaNetState) {},
+}
+
+// Parse RDMA network state version in <major>.<minor> format
+func parseStateVersion(version string) (major, minor int, err error) {
+	majorStr, minorStr, found := strings.Cut(version, ".")
+	if !found {
+		return 0, 0, fmt.Errorf("%w %q, expecting <major>.<minor>", ErrUnsupportedStateVersion, version)
+	}
+	if major, err = strconv.Atoi(majorStr); err == nil {
+		minor, err = strconv.Atoi(minorStr)
+	}
+	if err != nil || major < 0 || minor < 0 {
+		return 0, 0, fmt.Errorf("%w %q, expecting <major>.<minor>", ErrUnsupportedStateVersion, version)
+	}
+	return major, minor, nil
+}
+
+// UnmarshalJSON decodes RDMA network state, migrating states of older minor versions to RdmaNetStateVersion.
+// States of newer minor versions are decoded as is, ignoring fields unknown to this version,
+// states of other major versions are refused
+func (s *RdmaNetState) UnmarshalJSON(data []byte) error {
+	// Alias type without methods, avoiding recursion
+	type rdmaNetState RdmaNetState
+	state := rdmaNetState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	major, minor, err := parseStateVersion(state.Version)
+	if err != nil {
+		return err
+	}
+	currMajor, currMinor, err := parseStateVersion(RdmaNetStateVersion)
+	if err != nil {
+		return err
+	}
+	if major != currMajor {
+		return fmt.Errorf("%w %q, only %d.x is supported", ErrUnsupportedStateVersion, state.Version, currMajor)
+	}
+
+	*s = RdmaNetState(state)
+	if minor < currMinor {
+		for _, migrate := range rdmaNetStateMigrations[minor:currMinor] {
+			migrate(s)
+		}
+		s.Version = RdmaNetStateVersion
+	}
+	return nil
+}
@@ -17,13 +17,94 @@
 package types
 
 import (
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/containernetworking/cni/pkg/types"
 )
 
+// RDMA network modes
+const (
+	// RDMA devices are moved to container network namespace
+	RdmaNetModeExclusive = "exclusive"
+	// RDMA devices are left in current network namespace and shared with the container,
+	// requires RDMA subsystem namespace awareness mode to be set to shared
+	RdmaNetModeShared = "shared"
+)
+
 type RdmaNetConf struct {
 	types.NetConf
 	DeviceID string  `json:"deviceID"` // PCI address of a VF in valid sysfs format
 	Args     CNIArgs `json:"args"`     // optional arguments passed to CNI as defined in CNI spec 0.2.0
+	// RDMA device name in container, may contain {ifname} and {index} templates e.g "rdma{index}"
+	ContainerRdmaDevName string `json:"containerRdmaDevName,omitempty"`
+	// Move all RDMA devices associated with DeviceID to container
+	AllRdmaDevs bool `json:"allRdmaDevs,omitempty"`
+	// Regular expression RDMA devices associated with DeviceID must match to be moved to container
+	RdmaDevPattern string `json:"rdmaDevPattern,omitempty"`
+	// Port number RDMA devices associated with DeviceID must have to be moved to container
+	RdmaDevPort int `json:"rdmaDevPort,omitempty"`
+	// RDMA network mode ["exclusive" | "shared"], defaults to exclusive
+	Mode string `json:"mode,omitempty"`
+	// Switch RDMA subsystem to exclusive namespace awareness mode if possible
+	AutoExclusiveMode bool `json:"autoExclusiveMode,omitempty"`
+	// RDMA device to move to container, takes precedence over DeviceID
+	RdmaDevice string `json:"rdmaDevice,omitempty"`
+	// Directory to cache RDMA network state in, defaults to RDMA_CNI_STATE_DIR environment variable or /var/lib/cni/rdma
+	StateDir string `json:"stateDir,omitempty"`
+	// Fail if StateDir is not on a persistent file system, i.e it is on tmpfs or ramfs
+	RequirePersistentStateDir bool `json:"requirePersistentStateDir,omitempty"`
+	// Max time to wait for RDMA devices to be registered e.g "500ms", defaults to DefaultRdmaDevWaitTimeout
+	RdmaDevWaitTimeout *Duration `json:"rdmaDevWaitTimeout,omitempty"`
+	// GUID to set as node and port GUID of the InfiniBand VF DeviceID e.g "00:11:22:33:44:55:66:77"
+	GUID string `json:"guid,omitempty"`
+	// InfiniBand partition key RDMA devices must be members of e.g "0x8001"
+	Pkey string `json:"pkey,omitempty"`
+	// Also ensure the container IPoIB interface in prevResult is on Pkey
+	CheckIPoIBPkey bool          `json:"checkIPoIBPkey,omitempty"`
+	RuntimeConfig  RuntimeConfig `json:"runtimeConfig,omitempty"`
+}
+
+// DefaultRdmaDevWaitTimeout is the max time to wait for RDMA devices to be registered if not configured
+const DefaultRdmaDevWaitTimeout = 5 * time.Second
+
+// Get max time to wait for RDMA devices to be registered
+func (c *RdmaNetConf) GetRdmaDevWaitTimeout() time.Duration {
+	if c.RdmaDevWaitTimeout == nil {
+		return DefaultRdmaDevWaitTimeout
+	}
+	return c.RdmaDevWaitTimeout.Duration
+}
+
+// Duration is a time.Duration represented in JSON as a string e.g "5s"
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s, expecting a string e.g \"5s\"", data)
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = duration
+	return nil
+}
+
+// Runtime configurations passed to CNI via capabilities
+type RuntimeConfig struct {
+	RdmaDevice        string `json:"rdmaDevice,omitempty"`
+	DeviceID          string `json:"deviceID,omitempty"`
+	CNIDeviceInfoFile string `json:"CNIDeviceInfoFile,omitempty"`
+	GUID              string `json:"guid,omitempty"`
 }
 
 type CNIArgs struct {
@@ -32,13 +113,28 @@ type CNIArgs struct {
 
 type RdmaCNIArgs struct {
 	types.CommonArgs
-	Debug bool `json:"debug"` // Run CNI in debug mode
+	Debug      bool                       `json:"debug"`      // Run CNI in debug mode
+	RdmaDevice types.UnmarshallableString `json:"rdmaDevice"` // RDMA device to move to container
+}
+
+// RDMA device attributes
+type RdmaDevInfo struct {
+	Name string
+	// Node GUID in network byte order as in sysfs e.g "98:03:9b:03:00:9e:e3:5e"
+	NodeGUID string
+	// System image GUID, shared by RDMA devices of the same physical device
+	SysImageGUID    string
+	FirmwareVersion string
+	// Node type e.g "1: CA", empty if unavailable
+	NodeType string
+	// Port numbers e.g [1,2], empty if unavailable
+	Ports []string
 }
 
 // RDMA Network state struct version
-// minor should be bumped when new fields are added
+// minor should be bumped when new fields are added, along with a migration in rdmaNetStateMigrations
 // major should be bumped when non backward compatible changes are introduced
-const RdmaNetStateVersion = "1.0"
+const RdmaNetStateVersion = "1.5"
 
 func NewRdmaNetState() RdmaNetState {
 	return RdmaNetState{Version: RdmaNetStateVersion}
@@ -53,4 +149,66 @@ type RdmaNetState struct {
 	SandboxRdmaDevName string `json:"sandboxRdmaDevName"`
 	// RDMA device name in container
 	ContainerRdmaDevName string `json:"containerRdmaDevName"`
+	// Network name the RDMA device was attached for
+	Network string `json:"network,omitempty"`
+	// Container ID the RDMA device was attached for
+	ContainerID string `json:"containerID,omitempty"`
+	// Container interface name the RDMA device was attached for
+	IfName string `json:"ifName,omitempty"`
+	// Network namespace path the RDMA device was moved to
+	Netns string `json:"netns,omitempty"`
+	// RDMA devices moved to container, the first one is also reflected in
+	// SandboxRdmaDevName and ContainerRdmaDevName
+	RdmaDevs []RdmaDevState `json:"rdmaDevs,omitempty"`
+	// RDMA network mode the RDMA devices were attached with, empty for exclusive
+	Mode string `json:"mode,omitempty"`
+	// GUID set as node and port GUID of the VF DeviceID, empty if not set
+	GUID string `json:"guid,omitempty"`
+	// Node GUID of the VF DeviceID before GUID was set, restored on DEL as both node and port GUID.
+	// In network byte order as RdmaDevState NodeGUID
+	PrevGUID string `json:"prevGUID,omitempty"`
+}
+
+// Whether RDMA devices were shared with the container rather than moved to it
+func (s *RdmaNetState) IsShared() bool {
+	return s.Mode == RdmaNetModeShared
+}
+
+type RdmaDevState struct {
+	// RDMA device name as originally appeared in sandbox
+	SandboxRdmaDevName string `json:"sandboxRdmaDevName"`
+	// RDMA device name in container
+	ContainerRdmaDevName string `json:"containerRdmaDevName"`
+	// Node GUID of the RDMA device when it was attached, in network byte order. Identifies it regardless of its name
+	NodeGUID string `json:"nodeGUID,omitempty"`
+}
+
+// Get RDMA devices moved to container
+func (s *RdmaNetState) GetRdmaDevs() []RdmaDevState {
+	if len(s.RdmaDevs) > 0 {
+		return s.RdmaDevs
+	}
+	return []RdmaDevState{{SandboxRdmaDevName: s.SandboxRdmaDevName, ContainerRdmaDevName: s.ContainerRdmaDevName}}
+}
+
+// Whether the given RDMA device, by its name in sandbox, is attached by this state
+func (s *RdmaNetState) HasRdmaDev(rdmaDev string) bool {
+	if rdmaDev == "" {
+		return false
+	}
+	for _, dev := range s.GetRdmaDevs() {
+		if dev.SandboxRdmaDevName == rdmaDev {
+			return true
+		}
+	}
+	return false
+}
+
+// Set RDMA devices moved to container
+func (s *RdmaNetState) SetRdmaDevs(rdmaDevs []RdmaDevState) {
+	s.RdmaDevs = rdmaDevs
+	if len(rdmaDevs) > 0 {
+		s.SandboxRdmaDevName = rdmaDevs[0].SandboxRdmaDevName
+		s.ContainerRdmaDevName = rdmaDevs[0].ContainerRdmaDevName
+	}
 }
@@ -0,0 +1,158 @@
+// Copyright 2025 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func loadGoldenState(version string) (RdmaNetState, error) {
+	data, err := os.ReadFile(filepath.Join("testdata", "rdma-net-state-"+version+".json"))
+	Expect(err).ToNot(HaveOccurred())
+	state := RdmaNetState{}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+var _ = Describe("RDMA network state", func() {
+	attachedState := func(rdmaDevs []RdmaDevState, mode string) RdmaNetState {
+		state := NewRdmaNetState()
+		state.DeviceID = "0000:03:00.2"
+		state.Network = "rdma-net"
+		state.ContainerID = "a1b2c3d4e5f6"
+		state.IfName = "net1"
+		state.Netns = "/var/run/netns/cni-5ab1c2d3"
+		state.SetRdmaDevs(rdmaDevs)
+		state.Mode = mode
+		return state
+	}
+
+	Describe("Load historic formats", func() {
+		It("Should have a migration for each minor version", func() {
+			_, minor, err := parseStateVersion(RdmaNetStateVersion)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rdmaNetStateMigrations).To(HaveLen(minor))
+		})
+
+		DescribeTable("Should migrate state to the current version",
+			func(version string, expected func() RdmaNetState) {
+				state, err := loadGoldenState(version)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(state).To(Equal(expected()))
+			},
+			Entry("1.0", "1.0", func() RdmaNetState {
+				state := NewRdmaNetState()
+				state.DeviceID = "0000:03:00.2"
+				state.SetRdmaDevs([]RdmaDevState{{SandboxRdmaDevName: "mlx5_3", ContainerRdmaDevName: "mlx5_3"}})
+				return state
+			}),
+			Entry("1.1", "1.1", func() RdmaNetState {
+				return attachedState(
+					[]RdmaDevState{{SandboxRdmaDevName: "mlx5_3", ContainerRdmaDevName: "mlx5_3"}}, "")
+			}),
+			Entry("1.2", "1.2", func() RdmaNetState {
+				return attachedState([]RdmaDevState{
+					{SandboxRdmaDevName: "mlx5_3", ContainerRdmaDevName: "rdma0"},
+					{SandboxRdmaDevName: "mlx5_4", ContainerRdmaDevName: "rdma1"}}, "")
+			}),
+			Entry("1.3", "1.3", func() RdmaNetState {
+				return attachedState(
+					[]RdmaDevState{{SandboxRdmaDevName: "mlx5_3", ContainerRdmaDevName: "mlx5_3"}}, RdmaNetModeShared)
+			}),
+			Entry("1.4", "1.4", func() RdmaNetState {
+				return attachedState([]RdmaDevState{{SandboxRdmaDevName: "mlx5_3", ContainerRdmaDevName: "rdma0",
+					NodeGUID: "98:03:9b:03:00:9e:e3:5e"}}, "")
+			}),
+			Entry("1.5", "1.5", func() RdmaNetState {
+				state := attachedState([]RdmaDevState{{SandboxRdmaDevName: "mlx5_3", ContainerRdmaDevName: "mlx5_3",
+					NodeGUID: "98:03:9b:03:00:9e:e3:5e"}}, "")
+				state.GUID = "00:11:22:33:44:55:66:77"
+				state.PrevGUID = "98:03:9b:03:00:9e:e3:5e"
+				return state
+			}),
+		)
+	})
+
+	Describe("Load state of unsupported version", func() {
+		DescribeTable("Should fail",
+			func(data string) {
+				state := RdmaNetState{}
+				err := json.Unmarshal([]byte(data), &state)
+				Expect(errors.Is(err, ErrUnsupportedStateVersion)).To(BeTrue())
+			},
+			Entry("newer major version", `{"version":"2.0","deviceID":"0000:03:00.2"}`),
+			Entry("older major version", `{"version":"0.9","deviceID":"0000:03:00.2"}`),
+			Entry("missing version", `{"deviceID":"0000:03:00.2"}`),
+			Entry("malformed version", `{"version":"1.x","deviceID":"0000:03:00.2"}`),
+		)
+	})
+
+	Describe("Load state of newer minor version", func() {
+		It("Should load it as is, ignoring unknown fields", func() {
+			state := RdmaNetState{}
+			Expect(json.Unmarshal([]byte(`{"version":"1.99","deviceID":"0000:03:00.2","newField":true}`),
+				&state)).To(Succeed())
+			Expect(state).To(Equal(RdmaNetState{Version: "1.99", DeviceID: "0000:03:00.2"}))
+		})
+	})
+
+	Describe("Save and load state", func() {
+		It("Should round trip", func() {
+			saved := attachedState([]RdmaDevState{{SandboxRdmaDevName: "mlx5_3", ContainerRdmaDevName: "rdma0"}}, "")
+			data, err := json.Marshal(&saved)
+			Expect(err).ToNot(HaveOccurred())
+			loaded := RdmaNetState{}
+			Expect(json.Unmarshal(data, &loaded)).To(Succeed())
+			Expect(loaded).To(Equal(saved))
+		})
+	})
+})
+
+var _ = Describe("RDMA network configuration", func() {
+	Describe("RDMA device wait timeout", func() {
+		It("Should default to DefaultRdmaDevWaitTimeout", func() {
+			conf := RdmaNetConf{}
+			Expect(json.Unmarshal([]byte(`{"name":"rdma-net"}`), &conf)).To(Succeed())
+			Expect(conf.GetRdmaDevWaitTimeout()).To(Equal(DefaultRdmaDevWaitTimeout))
+		})
+		It("Should parse duration string", func() {
+			conf := RdmaNetConf{}
+			Expect(json.Unmarshal([]byte(`{"rdmaDevWaitTimeout":"1m30s"}`), &conf)).To(Succeed())
+			Expect(conf.GetRdmaDevWaitTimeout()).To(Equal(90 * time.Second))
+			data, err := json.Marshal(conf.RdmaDevWaitTimeout)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(data)).To(Equal(`"1m30s"`))
+		})
+		It("Should allow disabling the wait", func() {
+			conf := RdmaNetConf{}
+			Expect(json.Unmarshal([]byte(`{"rdmaDevWaitTimeout":"0s"}`), &conf)).To(Succeed())
+			Expect(conf.GetRdmaDevWaitTimeout()).To(BeZero())
+		})
+		It("Should fail on invalid duration", func() {
+			conf := RdmaNetConf{}
+			Expect(json.Unmarshal([]byte(`{"rdmaDevWaitTimeout":5}`), &conf)).ToNot(Succeed())
+			Expect(json.Unmarshal([]byte(`{"rdmaDevWaitTimeout":"5 seconds"}`), &conf)).ToNot(Succeed())
+		})
+	})
+})
@@ -17,14 +17,39 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/vishvananda/netlink"
 )
 
+var (
+	// AuxDevDir is the sysfs directory of auxiliary devices
+	AuxDevDir = "/sys/bus/auxiliary/devices"
+	// PciDevDir is the sysfs directory of PCI devices
+	PciDevDir = "/sys/bus/pci/devices"
+	// DevInfoCNIDir is the directory of device-info files written for CNI plugins as defined in
+	// Network Plumbing WG device-info specification
+	DevInfoCNIDir = "/var/run/k8s.cni.cncf.io/devinfo/cni"
+)
+
+const devInfoTypePci = "pci"
+
+// Device-info as defined in Network Plumbing WG device-info specification, only the fields of interest
+type deviceInfo struct {
+	Type    string `json:"type"`
+	Version string `json:"version"`
+	Pci     *struct {
+		PciAddress string `json:"pci-address"`
+	} `json:"pci,omitempty"`
+}
+
 // Get VF PCI device associated with the given MAC.
 // this method compares with administrative MAC for SRIOV configured net devices
 // TODO: move this method to github: Mellanox/sriovnet
@@ -69,3 +94,66 @@ func IsPCIAddress(pciAddress string) bool {
 	re := regexp.MustCompile(`^[0-9a-fA-F]{4}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}\.[0-7]$`)
 	return re.MatchString(pciAddress)
 }
+
+// Get PCI device the given auxiliary device (e.g mlx5_core.sf.4) was created on.
+func GetPciDevFromAuxDev(auxDev string) (string, error) {
+	auxDevPath, err := filepath.EvalSymlinks(filepath.Join(AuxDevDir, auxDev))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve auxiliary device %s. %v", auxDev, err)
+	}
+	pciDev := filepath.Base(filepath.Dir(auxDevPath))
+	if !IsPCIAddress(pciDev) {
+		return "", fmt.Errorf("auxiliary device %s parent %s is not a PCI device", auxDev, pciDev)
+	}
+	return pciDev, nil
+}
+
+// Get network device of the PF the given VF PCI device belongs to, and the index of the VF on it.
+// For example, for input 0000:03:00.2, returns ens1f0, 0
+func GetPfNetdevAndVfIndex(vfPciDev string) (string, int, error) {
+	pfPath, err := filepath.EvalSymlinks(filepath.Join(PciDevDir, vfPciDev, "physfn"))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to get PF of PCI device %s, it may not be a VF. %v", vfPciDev, err)
+	}
+	pfPciDev := filepath.Base(pfPath)
+	netdevs, err := os.ReadDir(filepath.Join(pfPath, "net"))
+	if err != nil || len(netdevs) == 0 {
+		return "", 0, fmt.Errorf("failed to get network device of PF %s", pfPciDev)
+	}
+
+	virtfns, _ := filepath.Glob(filepath.Join(pfPath, "virtfn*"))
+	for _, virtfn := range virtfns {
+		vfPath, err := filepath.EvalSymlinks(virtfn)
+		if err != nil || filepath.Base(vfPath) != vfPciDev {
+			continue
+		}
+		vfIndex, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(virtfn), "virtfn"))
+		if err != nil {
+			continue
+		}
+		return netdevs[0].Name(), vfIndex, nil
+	}
+	return "", 0, fmt.Errorf("failed to get index of VF %s on PF %s", vfPciDev, pfPciDev)
+}
+
+// Get path of device-info file written for CNI plugin of the given network attachment
+func GetCNIDeviceInfoPath(netName, containerID, ifName string) string {
+	fileName := fmt.Sprintf("%s-%s-%s-device.json", strings.ReplaceAll(netName, "/", "-"), containerID, ifName)
+	return filepath.Join(DevInfoCNIDir, fileName)
+}
+
+// Get PCI device from the given device-info file
+func GetDeviceIDFromDeviceInfoFile(devInfoPath string) (string, error) {
+	data, err := os.ReadFile(devInfoPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read device-info file %s. %w", devInfoPath, err)
+	}
+	devInfo := deviceInfo{}
+	if err = json.Unmarshal(data, &devInfo); err != nil {
+		return "", fmt.Errorf("failed to parse device-info file %s. %v", devInfoPath, err)
+	}
+	if devInfo.Type != devInfoTypePci || devInfo.Pci == nil || devInfo.Pci.PciAddress == "" {
+		return "", fmt.Errorf("device-info file %s does not describe a PCI device", devInfoPath)
+	}
+	return devInfo.Pci.PciAddress, nil
+}